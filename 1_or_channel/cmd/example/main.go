@@ -1,32 +1,71 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"syscall"
 	"time"
 
 	"or_channel/pkg/or"
 )
 
-// Helper function to create a channel that closes after a duration
-func sigAfter(name string, d time.Duration) <-chan struct{} {
-	ch := make(chan struct{})
-	go func() {
-		defer close(ch)
-		time.Sleep(d)
-		fmt.Printf("%s done\n", name)
-	}()
-	return ch
+// worker simulates a background job that fails after a duration
+func worker(failAfter time.Duration, errs chan<- error) {
+	time.Sleep(failAfter)
+	errs <- errors.New("worker crashed")
 }
 
-// Example usage of the or package
+// drainer simulates a job that takes drain to finish its work once stop closes, then closes done
+func drainer(drain time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	<-stop
+	time.Sleep(drain)
+	close(done)
+}
+
+// Example usage of the or package: shut down on SIGINT/SIGTERM, a timeout or a worker error,
+// whichever comes first, then wait for the jobs to drain
 func main() {
-	fmt.Println("Waiting for the first signal...")
+	timeout := flag.Duration("timeout", 5*time.Second, "shut down after this duration")
+	failAfter := flag.Duration("fail-after", 0, "simulate a worker error after this duration (0 disables)")
+	jobs := flag.Int("jobs", 3, "number of jobs to drain on shutdown, the n-th taking n*100ms")
+	drainTimeout := flag.Duration("drain-timeout", time.Second, "give up waiting for jobs to drain after this duration")
+	flag.Parse()
+
+	errs := make(chan error, 1)
+	if *failAfter > 0 {
+		go worker(*failAfter, errs)
+	}
 
-	sig1 := sigAfter("A", 2*time.Second)
-	sig2 := sigAfter("B", 1*time.Second)
-	sig3 := sigAfter("C", 3*time.Second)
+	stop := make(chan struct{})
+	dones := make([]<-chan struct{}, max(*jobs, 0))
+	for n := range dones {
+		done := make(chan struct{})
+		dones[n] = done
+		go drainer(time.Duration(n+1)*100*time.Millisecond, stop, done)
+	}
+
+	reasons := []string{"signal received", "timeout reached", "worker failed"}
+	fmt.Printf("Running; press Ctrl+C to stop (timeout %v)...\n", *timeout)
 
 	start := time.Now()
-	<-or.Or(sig1, sig2, sig3)
-	fmt.Printf("First signal received after %v\n", time.Since(start))
+	i := <-or.OrIndex(
+		or.Signal(os.Interrupt, syscall.SIGTERM),
+		or.After(*timeout),
+		or.FromErrChan(errs),
+	)
+	fmt.Printf("Shutting down after %v: %s\n", time.Since(start).Round(time.Millisecond), reasons[i])
+
+	close(stop)
+	start = time.Now()
+	deadline := or.After(*drainTimeout)
+	if <-or.OrIndex(or.AtLeast(min(len(dones)/2+1, len(dones)), dones...), deadline) == 0 {
+		fmt.Printf("Most jobs drained after %v\n", time.Since(start).Round(time.Millisecond))
+	}
+	if <-or.OrIndex(or.And(dones...), deadline) == 0 {
+		fmt.Printf("All jobs drained after %v\n", time.Since(start).Round(time.Millisecond))
+	} else {
+		fmt.Printf("Gave up waiting for jobs after %v\n", time.Since(start).Round(time.Millisecond))
+	}
 }
@@ -0,0 +1,60 @@
+package or
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// quorumChunkSize bounds the number of channels watched by a single AtLeast goroutine.
+// Unlike Or, AtLeast selects once per closed input, so it uses smaller chunks to keep
+// the total work close to linear in the number of inputs.
+const quorumChunkSize = 64
+
+// And returns a channel that closes when all of the channels in the argument list have closed.
+// It returns an already closed channel if no channels are provided.
+func And(channels ...<-chan struct{}) <-chan struct{} {
+	return AtLeast(len(channels), channels...)
+}
+
+// AtLeast returns a channel that closes when at least k of the channels in the argument list
+// have closed. It returns an already closed channel if k <= 0, and nil if k exceeds the number
+// of channels, since such a quorum can never be reached.
+func AtLeast(k int, channels ...<-chan struct{}) <-chan struct{} {
+	switch {
+	case k <= 0:
+		done := make(chan struct{})
+		close(done)
+		return done
+	case k > len(channels):
+		return nil
+	case k == 1:
+		return Or(channels...)
+	default:
+	}
+
+	done := make(chan struct{})
+	var closed atomic.Int64
+
+	// one goroutine per chunk; each one drops inputs from its select as they fire
+	// and the goroutine that reaches the quorum closes done
+	for start := 0; start < len(channels); start += quorumChunkSize {
+		chunk := channels[start:min(start+quorumChunkSize, len(channels))]
+		go func() {
+			cases := selectCases(append([]<-chan struct{}{done}, chunk...)...)
+			for len(cases) > 1 {
+				chosen, _, _ := reflect.Select(cases)
+				if chosen == 0 {
+					return
+				}
+				cases[chosen] = cases[len(cases)-1]
+				cases = cases[:len(cases)-1]
+				if closed.Add(1) == int64(k) {
+					close(done)
+					return
+				}
+			}
+		}()
+	}
+
+	return done
+}
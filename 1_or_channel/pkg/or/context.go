@@ -0,0 +1,101 @@
+package or
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OrContext returns a context that is cancelled as soon as any of the parents is cancelled.
+// Err and context.Cause of the returned context report those of the first cancelled parent,
+// so a parent reaching its deadline makes it report context.DeadlineExceeded;
+// its deadline is the earliest deadline among the parents, and values are looked up
+// in the parents in order.
+// With no parents the returned context is only cancelled by the returned CancelFunc.
+func OrContext(parents ...context.Context) (context.Context, context.CancelFunc) {
+	carrier, cancel := context.WithCancelCause(context.Background())
+	ctx := &orContext{parents: parents, done: make(chan struct{}), carrier: carrier}
+	// only the first of the parents and the CancelFunc sets the error
+	fire := func(err, cause error) {
+		ctx.mu.Lock()
+		defer ctx.mu.Unlock()
+		if ctx.err == nil {
+			ctx.err = err
+			cancel(cause)
+			close(ctx.done)
+		}
+	}
+	stop := func() { fire(context.Canceled, nil) }
+
+	for _, p := range parents {
+		if d, ok := p.Deadline(); ok && (!ctx.hasDeadline || d.Before(ctx.deadline)) {
+			ctx.deadline, ctx.hasDeadline = d, true
+		}
+	}
+
+	// a parent that is already done cancels the result right away
+	for _, p := range parents {
+		if err := p.Err(); err != nil {
+			fire(err, context.Cause(p))
+			return ctx, stop
+		}
+	}
+
+	dones := make([]<-chan struct{}, len(parents))
+	for i, p := range parents {
+		dones[i] = p.Done()
+	}
+	watch(dones, ctx.done, func(i int) {
+		fire(parents[i].Err(), context.Cause(parents[i]))
+	})
+
+	return ctx, stop
+}
+
+// orContext merges the cancellation, deadlines and values of several parents.
+// It has its own done channel and error, so contexts derived from it are cancelled
+// with the error it reports; the cause is kept by carrier for context.Cause.
+type orContext struct {
+	parents     []context.Context
+	deadline    time.Time
+	hasDeadline bool
+	done        chan struct{}
+	carrier     context.Context // cancelled with the cause before done is closed
+
+	mu  sync.Mutex
+	err error // set before done is closed
+}
+
+// Done returns a channel closed once the first parent is cancelled or the CancelFunc is called.
+func (c *orContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error of the first cancelled parent, or context.Canceled
+// if the CancelFunc came first.
+func (c *orContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Deadline returns the earliest deadline among the parents.
+func (c *orContext) Deadline() (time.Time, bool) {
+	return c.deadline, c.hasDeadline
+}
+
+// Value looks the key up in carrier first, then in the parents in order.
+// The only value of carrier is the cancellation state context.Cause reads.
+// Its done channel is not that of c, so the contexts derived from c are not
+// attached to carrier but wait for Done and take Err, as for any custom context.
+func (c *orContext) Value(key any) any {
+	if v := c.carrier.Value(key); v != nil {
+		return v
+	}
+	for _, p := range c.parents {
+		if v := p.Value(key); v != nil {
+			return v
+		}
+	}
+	return nil
+}
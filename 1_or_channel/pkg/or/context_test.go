@@ -0,0 +1,208 @@
+package or
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrContext_CancelledByAnyParent(t *testing.T) {
+	p1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	p2, cancel2 := context.WithCancel(context.Background())
+
+	ctx, cancel := OrContext(p1, p2)
+	defer cancel()
+
+	cancel2()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("OrContext was not cancelled after a parent was cancelled")
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", ctx.Err())
+	}
+}
+
+func TestOrContext_PropagatesCause(t *testing.T) {
+	errFatal := errors.New("fatal worker error")
+	p1, cancel1 := context.WithCancelCause(context.Background())
+	p2, cancel2 := context.WithCancelCause(context.Background())
+	defer cancel2(nil)
+
+	ctx, cancel := OrContext(p1, p2)
+	defer cancel()
+
+	cancel1(errFatal)
+	<-ctx.Done()
+
+	if cause := context.Cause(ctx); !errors.Is(cause, errFatal) {
+		t.Errorf("expected cause %v, got %v", errFatal, cause)
+	}
+}
+
+func TestOrContext_EarliestDeadline(t *testing.T) {
+	early := time.Now().Add(20 * time.Millisecond)
+	p1, cancel1 := context.WithDeadline(context.Background(), early.Add(time.Hour))
+	defer cancel1()
+	p2, cancel2 := context.WithDeadline(context.Background(), early)
+	defer cancel2()
+
+	ctx, cancel := OrContext(p1, context.Background(), p2)
+	defer cancel()
+
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(early) {
+		t.Errorf("expected deadline %v, got %v (ok=%v)", early, d, ok)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("OrContext did not expire at the earliest deadline")
+	}
+	if !errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", context.Cause(ctx))
+	}
+}
+
+func TestOrContext_DeadlineExceeded(t *testing.T) {
+	p1, cancel1 := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel1()
+	p2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	ctx, cancel := OrContext(p1, p2)
+	defer cancel()
+
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", ctx.Err())
+	}
+
+	// an already expired parent is reported the same way
+	expired, cancel3 := OrContext(p2, p1)
+	defer cancel3()
+	if !errors.Is(expired.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded for an expired parent, got %v", expired.Err())
+	}
+
+	// the CancelFunc reports context.Canceled, and later parents do not change it
+	p3, cancel4 := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel4()
+	stopped, stop := OrContext(p3)
+	stop()
+	<-p3.Done()
+	time.Sleep(10 * time.Millisecond)
+	if !errors.Is(stopped.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled after the CancelFunc, got %v", stopped.Err())
+	}
+}
+
+func TestOrContext_NoDeadline(t *testing.T) {
+	ctx, cancel := OrContext(context.Background())
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("expected no deadline")
+	}
+}
+
+func TestOrContext_AlreadyCancelledParent(t *testing.T) {
+	errStop := errors.New("stopped")
+	p, cancelP := context.WithCancelCause(context.Background())
+	cancelP(errStop)
+
+	ctx, cancel := OrContext(context.Background(), p)
+	defer cancel()
+
+	if ctx.Err() == nil {
+		t.Fatalf("expected OrContext to be cancelled immediately")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errStop) {
+		t.Errorf("expected cause %v, got %v", errStop, cause)
+	}
+}
+
+func TestOrContext_CancelFunc(t *testing.T) {
+	p, cancelP := context.WithCancel(context.Background())
+	defer cancelP()
+
+	ctx, cancel := OrContext(p)
+	cancel()
+
+	<-ctx.Done()
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", context.Cause(ctx))
+	}
+	if p.Err() != nil {
+		t.Errorf("cancelling OrContext must not cancel its parents")
+	}
+}
+
+func TestOrContext_ValuesFromParents(t *testing.T) {
+	type key string
+	p1 := context.WithValue(context.Background(), key("a"), 1)
+	p2 := context.WithValue(context.Background(), key("b"), 2)
+
+	ctx, cancel := OrContext(p1, p2)
+	defer cancel()
+
+	if v := ctx.Value(key("a")); v != 1 {
+		t.Errorf("expected value 1 for key a, got %v", v)
+	}
+	if v := ctx.Value(key("b")); v != 2 {
+		t.Errorf("expected value 2 for key b, got %v", v)
+	}
+}
+
+func TestOrContext_ChildContextsAreCancelled(t *testing.T) {
+	p, cancelP := context.WithCancel(context.Background())
+
+	ctx, cancel := OrContext(p)
+	defer cancel()
+	child, cancelChild := context.WithTimeout(ctx, time.Hour)
+	defer cancelChild()
+
+	cancelP()
+
+	select {
+	case <-child.Done():
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("child of OrContext was not cancelled")
+	}
+	if !errors.Is(child.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled for the child, got %v", child.Err())
+	}
+}
+
+func TestOrContext_ChildContextsReportDeadlineExceeded(t *testing.T) {
+	errStop := errors.New("stopped")
+	p1, cancel1 := context.WithTimeoutCause(context.Background(), 20*time.Millisecond, errStop)
+	defer cancel1()
+	p2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	ctx, cancel := OrContext(p1, p2)
+	defer cancel()
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+	grandchild, cancelGrandchild := context.WithTimeout(child, time.Hour)
+	defer cancelGrandchild()
+
+	select {
+	case <-grandchild.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("child of OrContext was not cancelled at the deadline of a parent")
+	}
+	for _, c := range []context.Context{child, grandchild} {
+		if !errors.Is(c.Err(), context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded for a derived context, got %v", c.Err())
+		}
+		if cause := context.Cause(c); !errors.Is(cause, errStop) {
+			t.Errorf("expected cause %v for a derived context, got %v", errStop, cause)
+		}
+	}
+}
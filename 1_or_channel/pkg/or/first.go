@@ -0,0 +1,49 @@
+package or
+
+import (
+	"reflect"
+	"sync"
+)
+
+// First returns a channel that delivers the first value received from any of the channels
+// in the argument list and is closed afterwards. If an input is closed before any value
+// arrives, the returned channel is closed without a value. Once something has been received,
+// the remaining inputs are no longer listened to.
+// It returns nil if no channels are provided.
+func First[T any](chans ...<-chan T) <-chan T {
+	if len(chans) == 0 {
+		return nil
+	}
+
+	// buffered, so the value is delivered even if nobody reads it
+	out := make(chan T, 1)
+	fired := make(chan struct{})
+	var once sync.Once
+
+	for start := 0; start < len(chans); start += chunkSize {
+		chunk := chans[start:min(start+chunkSize, len(chans))]
+		go func() {
+			cases := make([]reflect.SelectCase, len(chunk)+1)
+			cases[0] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(fired)}
+			for i, ch := range chunk {
+				cases[i+1] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+			}
+
+			chosen, v, ok := reflect.Select(cases)
+			if chosen == 0 {
+				return
+			}
+			once.Do(func() {
+				close(fired)
+				if ok {
+					// comma-ok keeps nil interface values from panicking
+					value, _ := v.Interface().(T)
+					out <- value
+				}
+				close(out)
+			})
+		}()
+	}
+
+	return out
+}
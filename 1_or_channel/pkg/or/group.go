@@ -0,0 +1,95 @@
+package or
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Group is an or-channel whose members can be added after creation.
+// Done closes as soon as any current or future member closes.
+// The zero value is an empty group ready to use; a Group must not be copied after first use.
+// It is safe for concurrent use.
+type Group struct {
+	mu   sync.Mutex
+	once sync.Once
+	done chan struct{}
+	n    int
+
+	// adds feeds new members to the watcher goroutine that still has room
+	// for room more channels
+	adds chan (<-chan struct{})
+	room int
+}
+
+// Add makes ch a member of the group. Adding to a group whose Done channel
+// is already closed only updates Len.
+func (g *Group) Add(ch <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.init()
+	g.n++
+
+	select {
+	case <-g.done:
+		return
+	default:
+	}
+
+	// members are watched in chunks, one goroutine per chunk
+	if g.room == 0 {
+		g.adds, g.room = make(chan (<-chan struct{})), chunkSize
+		go g.watch(g.done, g.adds)
+	}
+	g.room--
+
+	select {
+	case g.adds <- ch:
+	case <-g.done:
+	}
+}
+
+// Done returns a channel that closes when any member of the group closes.
+func (g *Group) Done() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.init()
+	return g.done
+}
+
+// Len returns the number of members added to the group.
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.n
+}
+
+// init lazily creates the done channel. It must be called with g.mu held.
+func (g *Group) init() {
+	if g.done == nil {
+		g.done = make(chan struct{})
+	}
+}
+
+// watch waits on the members received from adds and closes done when any of them closes.
+// It exits once done is closed.
+func (g *Group) watch(done chan struct{}, adds <-chan (<-chan struct{})) {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(adds)},
+	}
+	for {
+		chosen, v, _ := reflect.Select(cases)
+		switch chosen {
+		case 0:
+			return
+		case 1:
+			cases = append(cases, selectCases(v.Interface().(<-chan struct{}))...)
+		default:
+			g.once.Do(func() { close(done) })
+			return
+		}
+	}
+}
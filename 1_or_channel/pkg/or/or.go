@@ -1,7 +1,19 @@
 package or
 
+import (
+	"reflect"
+	"sync"
+)
+
+// chunkSize bounds the number of channels watched by a single goroutine.
+// reflect.Select cost grows with the number of cases, so very large inputs
+// are split into chunks that are selected on concurrently.
+const chunkSize = 1024
+
 // Or returns a channel that closes when one of the channels in the argument list closes.
 // It returns nil if no channels are provided.
+// The goroutines behind the result exit only once an input closes;
+// use OrWithStop if the inputs may never close.
 func Or(channels ...<-chan struct{}) <-chan struct{} {
 	// if no channels are provided, return nil
 	switch len(channels) {
@@ -14,22 +26,94 @@ func Or(channels ...<-chan struct{}) <-chan struct{} {
 	}
 
 	orDone := make(chan struct{})
+	watch(channels, nil, func(int) { close(orDone) })
 
-	go func() {
-		defer close(orDone)
-		switch len(channels) {
-		case 2:
-			select {
-			case <-channels[0]:
-			case <-channels[1]:
-			}
-		default:
-			select {
-			case <-channels[0]:
-			case <-Or(append(channels[1:], orDone)...):
+	return orDone
+}
+
+// OrWithStop is like Or, but also returns a stop function that tears down the
+// goroutines watching the inputs and waits for them to exit. Use it when the
+// inputs may never close. Once stopped, the returned channel only closes if
+// an input had already fired; stop is safe to call more than once.
+// It returns a nil channel if no channels are provided.
+func OrWithStop(channels ...<-chan struct{}) (<-chan struct{}, func()) {
+	switch len(channels) {
+	case 0:
+		return nil, func() {}
+	case 1:
+		return channels[0], func() {}
+	default:
+	}
+
+	orDone := make(chan struct{})
+	stopCh := make(chan struct{})
+	wait := watch(channels, stopCh, func(int) { close(orDone) })
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopCh) })
+		wait()
+	}
+
+	return orDone, stop
+}
+
+// OrIndex returns a channel that delivers the index of the first channel in the
+// argument list to close, and is closed afterwards.
+// It returns nil if no channels are provided.
+func OrIndex(channels ...<-chan struct{}) <-chan int {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	// buffered, so the index is delivered even if nobody reads it
+	index := make(chan int, 1)
+	watch(channels, nil, func(i int) {
+		index <- i
+		close(index)
+	})
+
+	return index
+}
+
+// watch starts goroutines that wait on channels and calls fire exactly once
+// with the index of the first channel to close. The goroutines exit as soon
+// as fire has been called or stop is closed; a nil stop never closes.
+// The returned function blocks until all goroutines have exited.
+func watch(channels []<-chan struct{}, stop <-chan struct{}, fire func(int)) (wait func()) {
+	fired := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	// one goroutine per chunk; every goroutine also watches fired,
+	// so all of them exit as soon as the first input fires
+	for start := 0; start < len(channels); start += chunkSize {
+		chunk := channels[start:min(start+chunkSize, len(channels))]
+		wg.Go(func() {
+			if i := waitAny(chunk, fired, stop); i >= 0 {
+				once.Do(func() {
+					close(fired)
+					fire(start + i)
+				})
 			}
-		}
-	}()
+		})
+	}
 
-	return orDone
+	return wg.Wait
+}
+
+// waitAny blocks until one of channels, fired or stop closes (or receives a value).
+// It returns the index of the channel that fired, or a negative value if fired or stop closed first.
+func waitAny(channels []<-chan struct{}, fired, stop <-chan struct{}) int {
+	chosen, _, _ := reflect.Select(selectCases(append([]<-chan struct{}{fired, stop}, channels...)...))
+	return chosen - 2
+}
+
+// selectCases builds receive cases for reflect.Select, one per channel.
+func selectCases(channels ...<-chan struct{}) []reflect.SelectCase {
+	cases := make([]reflect.SelectCase, len(channels))
+	for i, ch := range channels {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)}
+	}
+	return cases
 }
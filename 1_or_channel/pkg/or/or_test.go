@@ -1,6 +1,11 @@
 package or
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 )
@@ -102,3 +107,452 @@ func TestOr_ClosePropagation(t *testing.T) {
 		t.Errorf("Or did not return immediately with pre-closed channel, elapsed=%v", elapsed)
 	}
 }
+
+func TestOrIndex_NoChannels(t *testing.T) {
+	if ch := OrIndex(); ch != nil {
+		t.Errorf("expected nil channel when no input channels are provided")
+	}
+}
+
+func TestOrIndex_ReportsFirstClosed(t *testing.T) {
+	sigs := []<-chan struct{}{
+		sigAfter(80 * time.Millisecond),
+		sigAfter(10 * time.Millisecond),
+		sigAfter(150 * time.Millisecond),
+	}
+
+	select {
+	case i := <-OrIndex(sigs...):
+		if i != 1 {
+			t.Errorf("expected index 1, got %d", i)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("OrIndex did not fire at the earliest signal")
+	}
+}
+
+func TestOrIndex_SingleChannel(t *testing.T) {
+	ch := make(chan struct{})
+	close(ch)
+
+	if i := <-OrIndex(ch); i != 0 {
+		t.Errorf("expected index 0, got %d", i)
+	}
+}
+
+func TestOrIndex_ClosedAfterIndex(t *testing.T) {
+	ch := make(chan struct{})
+	close(ch)
+
+	index := OrIndex(make(chan struct{}), ch)
+	<-index
+	if _, ok := <-index; ok {
+		t.Errorf("expected index channel to be closed after delivering the index")
+	}
+}
+
+func TestOrIndex_Stress(t *testing.T) {
+	// index must be reported correctly across chunk boundaries
+	const n = 3*chunkSize + 7
+	channels := make([]<-chan struct{}, n)
+	for i := range n {
+		channels[i] = make(chan struct{})
+	}
+	want := 2*chunkSize + 3
+	ch := make(chan struct{})
+	close(ch)
+	channels[want] = ch
+
+	select {
+	case i := <-OrIndex(channels...):
+		if i != want {
+			t.Errorf("expected index %d, got %d", want, i)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Errorf("OrIndex timed out with large number of channels")
+	}
+}
+
+func TestOrWithStop_ClosesOnFirstSignal(t *testing.T) {
+	done, stop := OrWithStop(sigAfter(50*time.Millisecond), sigAfter(5*time.Millisecond))
+	defer stop()
+
+	select {
+	case <-done:
+	case <-time.After(20 * time.Millisecond):
+		t.Errorf("OrWithStop did not close early enough")
+	}
+}
+
+func TestOrWithStop_NoChannels(t *testing.T) {
+	done, stop := OrWithStop()
+	if done != nil {
+		t.Errorf("expected nil channel when no input channels are provided")
+	}
+	stop()
+}
+
+func TestOrWithStop_NoLeaksAfterStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// inputs that never close, spread over several chunks
+	channels := make([]<-chan struct{}, 3*chunkSize)
+	for i := range channels {
+		channels[i] = make(chan struct{})
+	}
+	done, stop := OrWithStop(channels...)
+
+	if running := runtime.NumGoroutine(); running <= before {
+		t.Fatalf("expected watcher goroutines to be running, got %d (before %d)", running, before)
+	}
+
+	stop()
+	stop() // must be idempotent
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked after stop: before=%d after=%d", before, after)
+	}
+	select {
+	case <-done:
+		t.Errorf("result channel must not close when stopped")
+	default:
+	}
+}
+
+func TestOrWithStop_StopAfterFire(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ch := make(chan struct{})
+	done, stop := OrWithStop(make(chan struct{}), ch)
+	close(ch)
+	<-done
+	stop()
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines leaked after fire: before=%d after=%d", before, after)
+	}
+}
+
+func TestAnd_NoChannels(t *testing.T) {
+	select {
+	case <-And():
+	default:
+		t.Errorf("expected closed channel when no input channels are provided")
+	}
+}
+
+func TestAnd_WaitsForAll(t *testing.T) {
+	sigs := []<-chan struct{}{
+		sigAfter(30 * time.Millisecond),
+		sigAfter(5 * time.Millisecond),
+		sigAfter(15 * time.Millisecond),
+	}
+	start := time.Now()
+
+	<-And(sigs...)
+
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("And closed before the last signal, elapsed=%v", elapsed)
+	}
+}
+
+func TestAnd_NotAllClosed(t *testing.T) {
+	closed := make(chan struct{})
+	close(closed)
+
+	select {
+	case <-And(closed, make(chan struct{})):
+		t.Errorf("And closed while an input is still open")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestAnd_Stress(t *testing.T) {
+	const n = 3*chunkSize + 7
+	channels := make([]<-chan struct{}, n)
+	for i := range n {
+		ch := make(chan struct{})
+		close(ch)
+		channels[i] = ch
+	}
+
+	select {
+	case <-And(channels...):
+	case <-time.After(500 * time.Millisecond):
+		t.Errorf("And timed out with large number of closed channels")
+	}
+}
+
+func TestAtLeast_Quorum(t *testing.T) {
+	sigs := []<-chan struct{}{
+		sigAfter(5 * time.Millisecond),
+		sigAfter(1 * time.Hour),
+		sigAfter(15 * time.Millisecond),
+		sigAfter(1 * time.Hour),
+	}
+	start := time.Now()
+
+	select {
+	case <-AtLeast(2, sigs...):
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("AtLeast did not close once the quorum was reached")
+	}
+
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("AtLeast closed before the quorum was reached, elapsed=%v", elapsed)
+	}
+}
+
+func TestAtLeast_Bounds(t *testing.T) {
+	select {
+	case <-AtLeast(0, make(chan struct{})):
+	default:
+		t.Errorf("expected closed channel for k <= 0")
+	}
+
+	if ch := AtLeast(3, make(chan struct{}), make(chan struct{})); ch != nil {
+		t.Errorf("expected nil channel when k exceeds the number of channels")
+	}
+}
+
+func TestFirst_NoChannels(t *testing.T) {
+	if ch := First[int](); ch != nil {
+		t.Errorf("expected nil channel when no input channels are provided")
+	}
+}
+
+func TestFirst_ForwardsFirstValue(t *testing.T) {
+	slow := make(chan string)
+	fast := make(chan string, 1)
+	fast <- "replica-2"
+
+	out := First[string](slow, fast)
+
+	select {
+	case v, ok := <-out:
+		if !ok || v != "replica-2" {
+			t.Errorf("expected value replica-2, got %q (ok=%v)", v, ok)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("First did not forward the value")
+	}
+	if _, ok := <-out; ok {
+		t.Errorf("expected output channel to be closed after the first value")
+	}
+}
+
+func TestFirst_StopsListening(t *testing.T) {
+	a := make(chan int, 1)
+	b := make(chan int, 1)
+	a <- 1
+
+	<-First[int](a, b)
+	b <- 2
+	time.Sleep(10 * time.Millisecond)
+
+	select {
+	case v := <-b:
+		if v != 2 {
+			t.Errorf("unexpected value %d", v)
+		}
+	default:
+		t.Errorf("First consumed a value after the first one was forwarded")
+	}
+}
+
+func TestFirst_ClosedInput(t *testing.T) {
+	closed := make(chan int)
+	close(closed)
+
+	select {
+	case v, ok := <-First[int](make(chan int), closed):
+		if ok {
+			t.Errorf("expected closed output without a value, got %d", v)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("First did not close after an input closed")
+	}
+}
+
+func TestFirst_NilInterfaceValue(t *testing.T) {
+	ch := make(chan error, 1)
+	ch <- nil
+
+	if err, ok := <-First[error](ch); !ok || err != nil {
+		t.Errorf("expected nil error value, got %v (ok=%v)", err, ok)
+	}
+}
+
+func TestGroup_Empty(t *testing.T) {
+	var g Group
+
+	if n := g.Len(); n != 0 {
+		t.Errorf("expected empty group, got Len=%d", n)
+	}
+	select {
+	case <-g.Done():
+		t.Errorf("empty group must not be done")
+	default:
+	}
+}
+
+func TestGroup_ClosesOnCurrentMember(t *testing.T) {
+	var g Group
+	g.Add(sigAfter(1 * time.Hour))
+	g.Add(sigAfter(5 * time.Millisecond))
+
+	select {
+	case <-g.Done():
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Group did not close when a member closed")
+	}
+}
+
+func TestGroup_ClosesOnFutureMember(t *testing.T) {
+	var g Group
+	done := g.Done()
+	g.Add(make(chan struct{}))
+
+	closed := make(chan struct{})
+	close(closed)
+	g.Add(closed)
+
+	select {
+	case <-done:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Group did not close when a member added later closed")
+	}
+
+	// adding to a done group only counts the member
+	g.Add(make(chan struct{}))
+	if n := g.Len(); n != 3 {
+		t.Errorf("expected Len=3, got %d", n)
+	}
+}
+
+func TestGroup_ConcurrentAdd(t *testing.T) {
+	var g Group
+	channels := make([]chan struct{}, 2*chunkSize+5)
+
+	var wg sync.WaitGroup
+	for i := range channels {
+		channels[i] = make(chan struct{})
+		wg.Go(func() { g.Add(channels[i]) })
+	}
+	wg.Wait()
+
+	if n := g.Len(); n != len(channels) {
+		t.Errorf("expected Len=%d, got %d", len(channels), n)
+	}
+	select {
+	case <-g.Done():
+		t.Fatalf("Group closed before any member closed")
+	default:
+	}
+
+	close(channels[len(channels)-1])
+
+	select {
+	case <-g.Done():
+	case <-time.After(50 * time.Millisecond):
+		t.Errorf("Group did not close across chunk boundaries")
+	}
+}
+
+func TestAfter(t *testing.T) {
+	start := time.Now()
+
+	<-After(10 * time.Millisecond)
+
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("After closed too early, elapsed=%v", elapsed)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := FromContext(ctx)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("FromContext did not close after cancel")
+	}
+}
+
+func TestFromErrChan(t *testing.T) {
+	errs := make(chan error)
+	done := FromErrChan(errs)
+
+	errs <- nil
+	select {
+	case <-done:
+		t.Fatalf("FromErrChan closed on a nil error")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	errs <- errors.New("fatal")
+	select {
+	case <-done:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("FromErrChan did not close on an error")
+	}
+}
+
+func TestFromErrChan_Closed(t *testing.T) {
+	errs := make(chan error)
+	close(errs)
+
+	select {
+	case <-FromErrChan(errs):
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("FromErrChan did not close when the error channel closed")
+	}
+}
+
+// benchmarkOr measures the time spent setting up Or and waiting for the result
+// to close after the last of n inputs fires, and reports the peak goroutine count.
+func benchmarkOr(b *testing.B, n int) {
+	var peak int
+	for b.Loop() {
+		b.StopTimer()
+		channels := make([]<-chan struct{}, n)
+		for i := range n - 1 {
+			channels[i] = make(chan struct{})
+		}
+		last := make(chan struct{})
+		channels[n-1] = last
+		before := runtime.NumGoroutine()
+		b.StartTimer()
+
+		done := Or(channels...)
+		b.StopTimer()
+		peak = max(peak, settledGoroutines()-before)
+		b.StartTimer()
+		close(last)
+		<-done
+	}
+	b.ReportMetric(float64(peak), "goroutines")
+}
+
+// settledGoroutines waits until the goroutine count stops growing and returns it.
+func settledGoroutines() int {
+	n := runtime.NumGoroutine()
+	for {
+		time.Sleep(100 * time.Microsecond)
+		m := runtime.NumGoroutine()
+		if m == n {
+			return n
+		}
+		n = m
+	}
+}
+
+func BenchmarkOr(b *testing.B) {
+	for _, n := range []int{10, 1_000, 100_000} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			benchmarkOr(b, n)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package or
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// randomized and fuzz tests; run them with -race to also check for data races:
+// go test -race ./pkg/or
+// go test -fuzz FuzzOr ./pkg/or
+
+const (
+	// quiet is how long a channel is watched to check that it stays open
+	quiet = time.Millisecond
+	// deadline is how long a channel is waited on before it is considered stuck
+	deadline = time.Second
+)
+
+// scenario is a set of channels to combine: how many there are, which of them
+// are closed before combining, and the order in which the others are closed.
+type scenario struct {
+	n         int
+	preClosed []bool
+	order     []int
+}
+
+// randomScenario builds a scenario with up to maxN channels from r.
+func randomScenario(r *rand.Rand, maxN int) scenario {
+	n := 1 + r.IntN(maxN)
+	sc := scenario{n: n, preClosed: make([]bool, n)}
+	// most scenarios start with every channel open
+	if r.IntN(4) == 0 {
+		for i := range n {
+			sc.preClosed[i] = r.IntN(8) == 0
+		}
+	}
+	for _, i := range r.Perm(n) {
+		if !sc.preClosed[i] {
+			sc.order = append(sc.order, i)
+		}
+	}
+	return sc
+}
+
+// scenarioFromBytes decodes a scenario from fuzzer input: the first byte picks
+// the number of channels, every following byte picks the next channel to close.
+func scenarioFromBytes(data []byte) scenario {
+	if len(data) == 0 {
+		return scenario{n: 1, preClosed: []bool{false}, order: []int{0}}
+	}
+	n := 1 + int(data[0])%(2*chunkSize)
+	sc := scenario{n: n, preClosed: make([]bool, n)}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	for _, b := range data[1:] {
+		if len(remaining) == 0 {
+			break
+		}
+		j := int(b) % len(remaining)
+		sc.order = append(sc.order, remaining[j])
+		remaining = append(remaining[:j], remaining[j+1:]...)
+	}
+	sc.order = append(sc.order, remaining...)
+	return sc
+}
+
+// channels creates the scenario's channels, closing the pre-closed ones.
+func (sc scenario) channels() ([]chan struct{}, []<-chan struct{}) {
+	chans := make([]chan struct{}, sc.n)
+	inputs := make([]<-chan struct{}, sc.n)
+	for i := range chans {
+		chans[i] = make(chan struct{})
+		if sc.preClosed[i] {
+			close(chans[i])
+		}
+		inputs[i] = chans[i]
+	}
+	return chans, inputs
+}
+
+func (sc scenario) anyPreClosed() bool {
+	return len(sc.order) < sc.n
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func expectOpen(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+		t.Fatalf("%s closed too early", what)
+	case <-time.After(quiet):
+	}
+}
+
+func expectClosed(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(deadline):
+		t.Fatalf("%s did not close", what)
+	}
+}
+
+// checkOr verifies that Or and OrIndex stay open until the first input closes,
+// then close exactly once and report that input.
+func checkOr(t *testing.T, sc scenario) {
+	t.Helper()
+	chans, inputs := sc.channels()
+
+	done := Or(inputs...)
+	index := OrIndex(inputs...)
+
+	if sc.anyPreClosed() {
+		expectClosed(t, done, "Or with a pre-closed input")
+		if i := <-index; !sc.preClosed[i] {
+			t.Fatalf("OrIndex reported open channel %d", i)
+		}
+	} else {
+		expectOpen(t, done, "Or")
+		first := sc.order[0]
+		close(chans[first])
+		sc.order = sc.order[1:]
+
+		expectClosed(t, done, "Or")
+		if i := <-index; i != first {
+			t.Fatalf("OrIndex reported %d, want %d", i, first)
+		}
+	}
+
+	if _, ok := <-index; ok {
+		t.Fatalf("OrIndex delivered more than one index")
+	}
+
+	// closing the remaining inputs must not disturb the result
+	for _, i := range sc.order {
+		close(chans[i])
+	}
+	if !isClosed(done) {
+		t.Fatalf("Or reopened")
+	}
+}
+
+// checkAtLeast verifies that AtLeast(k) stays open until k inputs have closed and closes afterwards.
+func checkAtLeast(t *testing.T, sc scenario, k int) {
+	t.Helper()
+	chans, inputs := sc.channels()
+
+	done := AtLeast(k, inputs...)
+	closed := sc.n - len(sc.order)
+
+	for _, i := range sc.order {
+		if closed >= k {
+			break
+		}
+		expectOpen(t, done, "AtLeast")
+		close(chans[i])
+		closed++
+	}
+	expectClosed(t, done, "AtLeast")
+}
+
+func TestOr_RandomCloseOrder(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed %d", seed)
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	for range 200 {
+		maxN := 16
+		if r.IntN(10) == 0 {
+			maxN = 3 * chunkSize
+		}
+		checkOr(t, randomScenario(r, maxN))
+	}
+}
+
+func TestAtLeast_RandomCloseOrder(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed %d", seed)
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	for range 100 {
+		sc := randomScenario(r, 16)
+		checkAtLeast(t, sc, 1+r.IntN(sc.n))
+	}
+}
+
+// TestOr_RandomTimings closes inputs concurrently after random delays
+// and checks that the reported input really is closed when the result fires.
+func TestOr_RandomTimings(t *testing.T) {
+	seed := uint64(time.Now().UnixNano())
+	t.Logf("seed %d", seed)
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	for range 50 {
+		n := 1 + r.IntN(64)
+		chans := make([]chan struct{}, n)
+		inputs := make([]<-chan struct{}, n)
+		for i := range chans {
+			chans[i] = make(chan struct{})
+			inputs[i] = chans[i]
+		}
+
+		index := OrIndex(inputs...)
+		done := Or(inputs...)
+		all := And(inputs...)
+		for _, ch := range chans {
+			time.AfterFunc(time.Duration(r.IntN(2000))*time.Microsecond, func() { close(ch) })
+		}
+
+		expectClosed(t, done, "Or")
+		if i := <-index; !isClosed(inputs[i]) {
+			t.Fatalf("OrIndex reported channel %d which is still open", i)
+		}
+
+		expectClosed(t, all, "And")
+		for i, ch := range inputs {
+			if !isClosed(ch) {
+				t.Fatalf("And closed while channel %d is still open", i)
+			}
+		}
+	}
+}
+
+func FuzzOr(f *testing.F) {
+	f.Add([]byte{0})
+	f.Add([]byte{1, 1})
+	f.Add([]byte{9, 3, 1, 4, 1, 5})
+	f.Add([]byte{255, 200, 100, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkOr(t, scenarioFromBytes(data))
+	})
+}
+
+func FuzzAtLeast(f *testing.F) {
+	f.Add(uint8(1), []byte{3, 2, 1, 0})
+	f.Add(uint8(4), []byte{4, 3, 0, 1, 2})
+	f.Add(uint8(0), []byte{7})
+
+	f.Fuzz(func(t *testing.T, k uint8, data []byte) {
+		sc := scenarioFromBytes(data)
+		// keep the quorum reachable and the scenario small enough to step through
+		if sc.n > 64 {
+			t.Skip()
+		}
+		checkAtLeast(t, sc, int(k)%(sc.n+1))
+	})
+}
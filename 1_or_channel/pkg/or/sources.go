@@ -0,0 +1,56 @@
+package or
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// After returns a channel that closes once d has elapsed.
+func After(d time.Duration) <-chan struct{} {
+	ch := make(chan struct{})
+	time.AfterFunc(d, func() { close(ch) })
+	return ch
+}
+
+// Signal returns a channel that closes when the process receives one of the given signals.
+// With no signals, it waits for os.Interrupt or SIGTERM rather than for any signal, as the
+// runtime and terminals send signals such as SIGURG, SIGCHLD and SIGWINCH on their own.
+// The signals stay captured until the first one arrives.
+func Signal(sigs ...os.Signal) <-chan struct{} {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan struct{})
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, sigs...)
+
+	go func() {
+		defer close(ch)
+		<-received
+		signal.Stop(received)
+	}()
+	return ch
+}
+
+// FromContext returns a channel that closes when ctx is done.
+func FromContext(ctx context.Context) <-chan struct{} {
+	return ctx.Done()
+}
+
+// FromErrChan returns a channel that closes when errs delivers a non-nil error or is closed.
+// Nil errors are ignored.
+func FromErrChan(errs <-chan error) <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		for err := range errs {
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
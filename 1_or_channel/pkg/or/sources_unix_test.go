@@ -0,0 +1,46 @@
+//go:build unix
+
+package or
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	sig := Signal(syscall.SIGUSR1)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+
+	select {
+	case <-sig:
+	case <-time.After(time.Second):
+		t.Fatalf("Signal did not close after the signal was received")
+	}
+}
+
+func TestSignal_DefaultsToShutdownSignals(t *testing.T) {
+	sig := Signal()
+
+	// the runtime sends SIGURG to preempt goroutines; it must not count
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGURG); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+	select {
+	case <-sig:
+		t.Fatalf("Signal closed on SIGURG")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send signal: %v", err)
+	}
+	select {
+	case <-sig:
+	case <-time.After(time.Second):
+		t.Fatalf("Signal did not close after SIGTERM was received")
+	}
+}
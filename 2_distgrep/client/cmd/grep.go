@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"client/internal/helpers/walk"
 	"client/internal/models"
 	"client/internal/service"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -17,20 +23,83 @@ var (
 	ignorecase   bool
 	countOnly    bool
 	fixedstring  bool
+	wordRegexp   bool
+	lineRegexp   bool
+	maxCount     int
 	printNumbers bool
+	onlyMatching bool
+	color        string
+	regexps      []string
+	patternFiles []string
+	recursive    bool
+	decompress   bool
+	text         bool
+	noBinary     bool
+	binaryFiles  string
+	withName     bool
+	noName       bool
+	listMatches  bool
+	listOthers   bool
+	include      []string
+	exclude      []string
+	excludeDir   []string
 	addrs        []string
+	replicas     int
 	quorum       int
+	chunkLines   int
+	remote       bool
+	shards       bool
+	chunkBytes   int64
+	compress     string
+	framing      string
+	caCert       string
+	cert         string
+	key          string
+	token        string
+	plainToken   bool
+	retries      int
+	retryBackoff time.Duration
+	timeout      time.Duration
 )
 
+// tokenEnv names the environment variable read for the bearer token when --token is not given,
+// which keeps the token out of the process list
+const tokenEnv = "DISTGREP_TOKEN"
+
+// exitError carries the exit code of a grep run through cobra to Execute.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 // runGrep executes the grep logic using package-level flag variables.
-func runGrep(args []string) {
-	pattern := args[0]
-	files := args[1:]
+// It returns an *exitError unless a line was selected, following grep's exit codes.
+func runGrep(cmd *cobra.Command, args []string) error {
+	// from here on, errors are reported by the service; cobra should print neither them nor usage
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
 
-	if len(files) == 0 {
-		// Read from stdin if no files provided
-		files = []string{"-"}
+	// with -e or -f, every argument is a file; otherwise the first one is the pattern
+	var files []string
+	patterns := append([]string{}, regexps...)
+	if len(regexps) == 0 && len(patternFiles) == 0 {
+		patterns = append(patterns, args[0])
+		files = args[1:]
+	} else {
+		files = args
+	}
+	for _, name := range patternFiles {
+		list, err := readPatterns(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return &exitError{code: int(service.StatusInputError)}
+		}
+		patterns = append(patterns, list...)
 	}
+	// without files, stdin is read (or the working directory searched with -r)
 
 	beforeCtx := before
 	afterCtx := after
@@ -47,20 +116,198 @@ func runGrep(args []string) {
 		After:        afterCtx,
 		Before:       beforeCtx,
 		CountOnly:    countOnly,
+		WordMatch:    wordRegexp,
+		LineMatch:    lineRegexp,
+		// a negative limit means no limit
+		MaxCount: max(maxCount, 0),
+	}
+
+	colored, err := colorOutput(color)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return &exitError{code: int(service.StatusInputError)}
+	}
+
+	binary, err := binaryMode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return &exitError{code: int(service.StatusInputError)}
 	}
 
-	if err := service.Run(pattern, files, addrs, flags, quorum); err != nil {
+	switch compress {
+	case service.CompressNone, service.CompressGzip, service.CompressZstd:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid argument %q for --compress; valid arguments are none, gzip and zstd\n", compress)
+		return &exitError{code: int(service.StatusInputError)}
+	}
+	switch framing {
+	case service.FramingJSON, service.FramingProtobuf:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid argument %q for --framing; valid arguments are json and protobuf\n", framing)
+		return &exitError{code: int(service.StatusInputError)}
+	}
+
+	if key != "" && cert == "" {
+		fmt.Fprintln(os.Stderr, "--key needs --cert")
+		return &exitError{code: int(service.StatusInputError)}
+	}
+	if token == "" {
+		token = os.Getenv(tokenEnv)
+	}
+
+	if err := checkRemote(files); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		return &exitError{code: int(service.StatusInputError)}
+	}
+
+	// like grep, -m 0 selects nothing and reads no input
+	if maxCount == 0 {
+		return &exitError{code: int(service.StatusNoneFound)}
+	}
+
+	names := service.NamesAuto
+	switch {
+	case noName:
+		names = service.NamesNever
+	case withName:
+		names = service.NamesAlways
+	}
+	list := service.ListNone
+	switch {
+	case listMatches:
+		list = service.ListMatches
+	case listOthers:
+		list = service.ListNonMatches
+	}
+
+	opts := service.Options{
+		Recursive:    recursive,
+		Names:        names,
+		List:         list,
+		OnlyMatching: onlyMatching,
+		Color:        colored,
+		Decompress:   decompress,
+		Binary:       binary,
+		Filter: walk.Filter{
+			Include:    include,
+			Exclude:    exclude,
+			ExcludeDir: excludeDir,
+		},
+		Addrs:        addrs,
+		Replicas:     replicas,
+		Quorum:       quorum,
+		ChunkLines:   chunkLines,
+		Remote:       remote,
+		Shards:       shards,
+		ChunkBytes:   chunkBytes,
+		Compress:     compress,
+		Framing:      framing,
+		CACert:       caCert,
+		Cert:         cert,
+		Key:          key,
+		Token:        token,
+		PlainToken:   plainToken,
+		Retries:      retries,
+		RetryBackoff: retryBackoff,
+		Timeout:      timeout,
+	}
+
+	status, err := service.Run(patterns, files, flags, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return &exitError{code: int(service.StatusInputError)}
+	}
+	if status != service.StatusSelected {
+		return &exitError{code: int(status)}
+	}
+	return nil
+}
+
+// readPatterns reads a pattern file with one pattern per line; "-" reads stdin.
+// An empty file yields no patterns and thus selects no line.
+func readPatterns(name string) ([]string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// colorOutput tells whether to color output for --color=WHEN, accepting grep's synonyms.
+// With auto, output is colored when written to a terminal.
+func colorOutput(when string) (bool, error) {
+	switch when {
+	case "always", "yes", "force":
+		return true, nil
+	case "never", "no", "none":
+		return false, nil
+	case "auto", "tty", "if-tty":
+		fi, err := os.Stdout.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb", nil
+	}
+	return false, fmt.Errorf("invalid argument %q for --color; valid arguments are always, never and auto", when)
+}
+
+// binaryMode returns the handling of binary data chosen with -a, -I or --binary-files.
+func binaryMode() (service.BinaryMode, error) {
+	switch {
+	case text:
+		return service.BinaryText, nil
+	case noBinary:
+		return service.BinaryWithoutMatch, nil
+	}
+	switch binaryFiles {
+	case "binary":
+		return service.BinaryMatches, nil
+	case "text":
+		return service.BinaryText, nil
+	case "without-match":
+		return service.BinaryWithoutMatch, nil
+	}
+	return 0, fmt.Errorf("invalid argument %q for --binary-files; valid arguments are binary, text and without-match", binaryFiles)
+}
+
+// checkRemote rejects what --remote cannot do: servers read named files as they are.
+func checkRemote(files []string) error {
+	switch {
+	case !remote && shards:
+		return errors.New("--shards needs --remote")
+	case !remote:
+		return nil
+	case len(files) == 0 || slices.Contains(files, "-"):
+		return errors.New("--remote needs FILE operands naming files on the servers")
+	case recursive:
+		return errors.New("--remote cannot be combined with --recursive")
+	case decompress:
+		return errors.New("--remote cannot be combined with --decompress")
+	case shards && replicas > 1:
+		return errors.New("--shards cannot be combined with --replicas, a shard is held by one server")
+	}
+	return nil
+}
+
+// grepArgs requires a pattern argument unless patterns are given with -e or -f.
+func grepArgs(cmd *cobra.Command, args []string) error {
+	if len(regexps) == 0 && len(patternFiles) == 0 {
+		return cobra.MinimumNArgs(1)(cmd, args)
 	}
+	return nil
 }
 
 var grepCmd = &cobra.Command{
 	Use:   "grep [PATTERN] [FILE...]",
 	Short: "Parse grep-like flags and addresses",
-	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		runGrep(args)
-	},
+	Args:  grepArgs,
+	RunE:  runGrep,
 }
 
 func init() {
@@ -71,7 +318,44 @@ func init() {
 	grepCmd.Flags().BoolVarP(&ignorecase, "ignore-case", "i", false, "Ignore case distinctions in patterns and data")
 	grepCmd.Flags().BoolVarP(&countOnly, "count", "c", false, "Print only a count of selected lines per FILE")
 	grepCmd.Flags().BoolVarP(&fixedstring, "fixed-string", "F", false, "Interpret PATTERN as a fixed string, not a regular expression")
+	grepCmd.Flags().BoolVarP(&wordRegexp, "word-regexp", "w", false, "Select only lines where a match forms a whole word")
+	grepCmd.Flags().BoolVarP(&lineRegexp, "line-regexp", "x", false, "Select only lines where a match spans the whole line")
+	grepCmd.Flags().IntVarP(&maxCount, "max-count", "m", -1, "Stop reading a FILE after NUM selected lines")
 	grepCmd.Flags().BoolVarP(&printNumbers, "print-numbers", "n", false, "Print line numbers with output lines")
+	grepCmd.Flags().StringArrayVarP(&regexps, "regexp", "e", nil, "Use PATTERN for matching; repeat to match any of several patterns")
+	grepCmd.Flags().StringArrayVarP(&patternFiles, "file", "f", nil, "Take patterns from FILE, one per line")
+	grepCmd.Flags().BoolVarP(&onlyMatching, "only-matching", "o", false, "Print only the matched parts of matching lines, each on its own line")
+	grepCmd.Flags().StringVar(&color, "color", "never", "Highlight matches, file names and line numbers: always, never or auto")
+	grepCmd.Flags().Lookup("color").NoOptDefVal = "auto"
+	grepCmd.Flags().BoolVarP(&withName, "with-filename", "H", false, "Print the file name for each match")
+	grepCmd.Flags().BoolVarP(&noName, "no-filename", "h", false, "Suppress the file name prefix on output")
+	grepCmd.Flags().BoolVarP(&listMatches, "files-with-matches", "l", false, "Print only names of FILEs with selected lines")
+	grepCmd.Flags().BoolVarP(&listOthers, "files-without-match", "L", false, "Print only names of FILEs with no selected lines")
+	// -h is taken by --no-filename, as in grep, so help gets no shorthand
+	grepCmd.Flags().Bool("help", false, "Help for grep")
+	grepCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Read all files under each directory, recursively")
+	grepCmd.Flags().BoolVarP(&text, "text", "a", false, "Process binary data as if it were text")
+	grepCmd.Flags().BoolVarP(&noBinary, "I", "I", false, "Assume binary data does not match, like --binary-files=without-match")
+	grepCmd.Flags().StringVar(&binaryFiles, "binary-files", "binary", "How to handle binary data: binary, text or without-match")
+	grepCmd.Flags().BoolVarP(&decompress, "decompress", "z", false, "Fail on inputs that are not gzip, bzip2 or zstd compressed (compressed inputs are always decompressed)")
+	grepCmd.Flags().StringArrayVar(&include, "include", nil, "Search only files whose base name matches GLOB")
+	grepCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "Skip files whose base name matches GLOB")
+	grepCmd.Flags().StringArrayVar(&excludeDir, "exclude-dir", nil, "Skip directories whose base name matches GLOB when recursing")
 	grepCmd.Flags().StringSliceVar(&addrs, "addrs", nil, "Comma-separated list of server addresses")
-	grepCmd.Flags().IntVar(&quorum, "quorum", 0, "Quorum of successful servers required (default: majority)")
+	grepCmd.Flags().IntVar(&replicas, "replicas", service.DefaultReplicas, "Number of distinct servers each chunk is sent to")
+	grepCmd.Flags().IntVar(&quorum, "quorum", 0, "Number of replicas that must return identical results (default: majority of replicas)")
+	grepCmd.Flags().IntVar(&chunkLines, "chunk-lines", service.DefaultChunkLines, "Number of lines sent to a server per task")
+	grepCmd.Flags().BoolVar(&remote, "remote", false, "Search FILEs under the root directories of the servers instead of sending their lines")
+	grepCmd.Flags().Int64Var(&chunkBytes, "chunk-bytes", service.DefaultChunkBytes, "Number of bytes of a FILE searched per task with --remote")
+	grepCmd.Flags().BoolVar(&shards, "shards", false, "With --remote, every server holds its own shard of the FILEs, reported as FILE@HOST:PORT")
+	grepCmd.Flags().StringVar(&compress, "compress", service.DefaultCompress, "Compress tasks and results sent over HTTP: none, gzip or zstd")
+	grepCmd.Flags().StringVar(&framing, "framing", service.DefaultFraming, "Frame tasks and results sent over HTTP as json or protobuf")
+	grepCmd.Flags().StringVar(&caCert, "ca-cert", "", "Verify https and grpcs servers with the CA certificates in FILE instead of the system roots")
+	grepCmd.Flags().StringVar(&cert, "cert", "", "Present the client certificate in FILE to servers requiring one")
+	grepCmd.Flags().StringVar(&key, "key", "", "Private key FILE of --cert (default: read from the --cert file)")
+	grepCmd.Flags().StringVar(&token, "token", "", "Send TOKEN as bearer token to https and grpcs servers requiring one (default: $"+tokenEnv+")")
+	grepCmd.Flags().BoolVar(&plainToken, "insecure-token", false, "Also send the token to http and grpc servers, in plain text")
+	grepCmd.Flags().IntVar(&retries, "retries", service.DefaultRetries, "Number of times a failed task is re-sent to another server")
+	grepCmd.Flags().DurationVar(&retryBackoff, "retry-backoff", service.DefaultRetryBackoff, "Delay before the first retry, doubled on every further retry")
+	grepCmd.Flags().DurationVar(&timeout, "timeout", service.DefaultTimeout, "Time a server has to answer one request before it is retried on another server (0 for no limit)")
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"client/internal/service"
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -10,17 +12,21 @@ var rootCmd = &cobra.Command{
 	Use:   "grep [PATTERN] [FILE...]",
 	Short: "A distributed grep tool",
 	Long:  `A minimal grep implementation using Cobra CLI framework`,
-	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		runGrep(args)
-	},
+	Args:  grepArgs,
+	RunE:  runGrep,
 }
 
-// Execute is the main entry point for the CLI application
+// Execute is the main entry point for the CLI application.
+// Like grep, it exits with 0 if a line was selected, 1 if none was and 2 on errors.
 func Execute() {
 	err := rootCmd.Execute()
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
 	if err != nil {
-		os.Exit(1)
+		// usage errors, already printed by cobra
+		os.Exit(2)
 	}
 }
 
@@ -33,7 +39,44 @@ func init() {
 	rootCmd.Flags().BoolVarP(&ignorecase, "ignore-case", "i", false, "Ignore case distinctions in patterns and data")
 	rootCmd.Flags().BoolVarP(&countOnly, "count", "c", false, "Print only a count of selected lines per FILE")
 	rootCmd.Flags().BoolVarP(&fixedstring, "fixed-string", "F", false, "Interpret PATTERN as a fixed string, not a regular expression")
+	rootCmd.Flags().BoolVarP(&wordRegexp, "word-regexp", "w", false, "Select only lines where a match forms a whole word")
+	rootCmd.Flags().BoolVarP(&lineRegexp, "line-regexp", "x", false, "Select only lines where a match spans the whole line")
+	rootCmd.Flags().IntVarP(&maxCount, "max-count", "m", -1, "Stop reading a FILE after NUM selected lines")
 	rootCmd.Flags().BoolVarP(&printNumbers, "print-numbers", "n", false, "Print line numbers with output lines")
+	rootCmd.Flags().StringArrayVarP(&regexps, "regexp", "e", nil, "Use PATTERN for matching; repeat to match any of several patterns")
+	rootCmd.Flags().StringArrayVarP(&patternFiles, "file", "f", nil, "Take patterns from FILE, one per line")
+	rootCmd.Flags().BoolVarP(&onlyMatching, "only-matching", "o", false, "Print only the matched parts of matching lines, each on its own line")
+	rootCmd.Flags().StringVar(&color, "color", "never", "Highlight matches, file names and line numbers: always, never or auto")
+	rootCmd.Flags().Lookup("color").NoOptDefVal = "auto"
+	rootCmd.Flags().BoolVarP(&withName, "with-filename", "H", false, "Print the file name for each match")
+	rootCmd.Flags().BoolVarP(&noName, "no-filename", "h", false, "Suppress the file name prefix on output")
+	rootCmd.Flags().BoolVarP(&listMatches, "files-with-matches", "l", false, "Print only names of FILEs with selected lines")
+	rootCmd.Flags().BoolVarP(&listOthers, "files-without-match", "L", false, "Print only names of FILEs with no selected lines")
+	// -h is taken by --no-filename, as in grep, so help gets no shorthand
+	rootCmd.Flags().Bool("help", false, "Help for grep")
+	rootCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Read all files under each directory, recursively")
+	rootCmd.Flags().BoolVarP(&text, "text", "a", false, "Process binary data as if it were text")
+	rootCmd.Flags().BoolVarP(&noBinary, "I", "I", false, "Assume binary data does not match, like --binary-files=without-match")
+	rootCmd.Flags().StringVar(&binaryFiles, "binary-files", "binary", "How to handle binary data: binary, text or without-match")
+	rootCmd.Flags().BoolVarP(&decompress, "decompress", "z", false, "Fail on inputs that are not gzip, bzip2 or zstd compressed (compressed inputs are always decompressed)")
+	rootCmd.Flags().StringArrayVar(&include, "include", nil, "Search only files whose base name matches GLOB")
+	rootCmd.Flags().StringArrayVar(&exclude, "exclude", nil, "Skip files whose base name matches GLOB")
+	rootCmd.Flags().StringArrayVar(&excludeDir, "exclude-dir", nil, "Skip directories whose base name matches GLOB when recursing")
 	rootCmd.Flags().StringSliceVar(&addrs, "addrs", nil, "Comma-separated list of server addresses")
-	rootCmd.Flags().IntVar(&quorum, "quorum", 0, "Quorum of successful servers required (default: majority)")
+	rootCmd.Flags().IntVar(&replicas, "replicas", service.DefaultReplicas, "Number of distinct servers each chunk is sent to")
+	rootCmd.Flags().IntVar(&quorum, "quorum", 0, "Number of replicas that must return identical results (default: majority of replicas)")
+	rootCmd.Flags().IntVar(&chunkLines, "chunk-lines", service.DefaultChunkLines, "Number of lines sent to a server per task")
+	rootCmd.Flags().BoolVar(&remote, "remote", false, "Search FILEs under the root directories of the servers instead of sending their lines")
+	rootCmd.Flags().Int64Var(&chunkBytes, "chunk-bytes", service.DefaultChunkBytes, "Number of bytes of a FILE searched per task with --remote")
+	rootCmd.Flags().BoolVar(&shards, "shards", false, "With --remote, every server holds its own shard of the FILEs, reported as FILE@HOST:PORT")
+	rootCmd.Flags().StringVar(&compress, "compress", service.DefaultCompress, "Compress tasks and results sent over HTTP: none, gzip or zstd")
+	rootCmd.Flags().StringVar(&framing, "framing", service.DefaultFraming, "Frame tasks and results sent over HTTP as json or protobuf")
+	rootCmd.Flags().StringVar(&caCert, "ca-cert", "", "Verify https and grpcs servers with the CA certificates in FILE instead of the system roots")
+	rootCmd.Flags().StringVar(&cert, "cert", "", "Present the client certificate in FILE to servers requiring one")
+	rootCmd.Flags().StringVar(&key, "key", "", "Private key FILE of --cert (default: read from the --cert file)")
+	rootCmd.Flags().StringVar(&token, "token", "", "Send TOKEN as bearer token to https and grpcs servers requiring one (default: $"+tokenEnv+")")
+	rootCmd.Flags().BoolVar(&plainToken, "insecure-token", false, "Also send the token to http and grpc servers, in plain text")
+	rootCmd.Flags().IntVar(&retries, "retries", service.DefaultRetries, "Number of times a failed task is re-sent to another server")
+	rootCmd.Flags().DurationVar(&retryBackoff, "retry-backoff", service.DefaultRetryBackoff, "Delay before the first retry, doubled on every further retry")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", service.DefaultTimeout, "Time a server has to answer one request before it is retried on another server (0 for no limit)")
 }
@@ -2,20 +2,37 @@ package client_test
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // buildBinaries builds the server and client binaries and returns their absolute paths.
-func buildBinaries(t *testing.T) (serverBin, clientBin string) {
+func buildBinaries(t testing.TB) (serverBin, clientBin string) {
 	t.Helper()
 
 	wd, err := os.Getwd()
@@ -51,7 +68,7 @@ func buildBinaries(t *testing.T) (serverBin, clientBin string) {
 }
 
 // getFreePort asks the OS for a free TCP port and returns it.
-func getFreePort(t *testing.T) int {
+func getFreePort(t testing.TB) int {
 	t.Helper()
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -61,15 +78,23 @@ func getFreePort(t *testing.T) int {
 	return l.Addr().(*net.TCPAddr).Port
 }
 
-// startServers launches n server processes on free ports and waits until their /health responds.
-func startServers(t *testing.T, serverBin string, n int) ([]*exec.Cmd, []string) {
+// startServers launches n server processes on free ports, with args added to their
+// command lines, and waits until their /health responds.
+func startServers(t testing.TB, serverBin string, n int, args ...string) ([]*exec.Cmd, []string) {
+	t.Helper()
+	return startServersWith(t, serverBin, n, "http", http.DefaultClient, args...)
+}
+
+// startServersWith is startServers for servers whose health endpoints are reached
+// with the given scheme and HTTP client, such as servers serving HTTPS.
+func startServersWith(t testing.TB, serverBin string, n int, scheme string, client *http.Client, args ...string) ([]*exec.Cmd, []string) {
 	t.Helper()
 	cmds := make([]*exec.Cmd, 0, n)
 	addrs := make([]string, 0, n)
 	for i := 0; i < n; i++ {
 		port := getFreePort(t)
 		addr := fmt.Sprintf("127.0.0.1:%d", port)
-		cmd := exec.Command(serverBin, fmt.Sprintf("-port=%d", port))
+		cmd := exec.Command(serverBin, append([]string{fmt.Sprintf("-port=%d", port)}, args...)...)
 		// Detach stdio but keep for debugging if needed
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -85,7 +110,7 @@ func startServers(t *testing.T, serverBin string, n int) ([]*exec.Cmd, []string)
 	for _, addr := range addrs {
 		ok := false
 		for time.Now().Before(deadline) {
-			resp, err := http.Get("http://" + addr + "/health")
+			resp, err := client.Get(scheme + "://" + addr + "/health")
 			if err == nil {
 				_ = resp.Body.Close()
 				if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
@@ -114,20 +139,122 @@ func startServers(t *testing.T, serverBin string, n int) ([]*exec.Cmd, []string)
 	return cmds, addrs
 }
 
+// startGRPCServers launches n server processes that also serve gRPC, with args added
+// to their command lines, and returns their grpc:// addresses once gRPC accepts connections.
+func startGRPCServers(t testing.TB, serverBin string, n int, args ...string) []string {
+	t.Helper()
+	addrs := make([]string, 0, n)
+	for range n {
+		port := getFreePort(t)
+		startServers(t, serverBin, 1, append([]string{fmt.Sprintf("-grpc-port=%d", port)}, args...)...)
+		addr := fmt.Sprintf("127.0.0.1:%d", port)
+		waitListening(t, addr)
+		addrs = append(addrs, "grpc://"+addr)
+	}
+	return addrs
+}
+
+// waitListening waits until a server accepts TCP connections at addr.
+func waitListening(t testing.TB, addr string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server %s did not start in time", addr)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// startFailingServer starts a stub server that passes health checks but fails every grep request.
+func startFailingServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// startHangingServer starts a stub server that passes health checks but never answers a grep request.
+func startHangingServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// the request is cancelled once the client goes away, provided its body was read
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// startLyingServer starts a stub server that passes health checks but answers every grep request
+// with a bogus match.
+func startLyingServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"found_blocks":[{"start_line_number":1,"lines":["bogus"]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
 // runClient executes the distributed grep client with given args and returns stdout.
 func runClient(t *testing.T, clientBin string, args ...string) string {
 	t.Helper()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, clientBin, args...)
-	out, err := cmd.CombinedOutput()
+	// stderr carries diagnostics such as retried requests, keep it out of the compared output
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
 	if err != nil && ctx.Err() == nil {
 		// The client returns non-zero on errors only. Surface stderr in failure.
-		t.Fatalf("client failed: %v\n%s", err, string(out))
+		t.Fatalf("client failed: %v\n%s%s", err, string(out), stderr.String())
 	}
 	return string(out)
 }
 
+// runClientStatus executes the client and returns stdout, stderr and the exit code.
+func runClientStatus(t *testing.T, clientBin string, args ...string) (string, string, int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, clientBin, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		t.Fatalf("client timed out\n%s%s", string(out), stderr.String())
+	}
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("run client: %v", err)
+	}
+	return string(out), stderr.String(), code
+}
+
 // runSystemGrep runs the system grep with given args and returns stdout.
 func runSystemGrep(t *testing.T, args ...string) string {
 	t.Helper()
@@ -141,8 +268,23 @@ func runSystemGrep(t *testing.T, args ...string) string {
 	return string(out)
 }
 
+// runSystemGrepStatus runs the system grep with given args and returns its exit code.
+func runSystemGrepStatus(t *testing.T, args ...string) int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := exec.CommandContext(ctx, "grep", args...).Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("run grep: %v", err)
+	}
+	return 0
+}
+
 // writeTempFile writes lines to a temp file and returns its path.
-func writeTempFile(t *testing.T, lines []string) string {
+func writeTempFile(t testing.TB, lines []string) string {
 	t.Helper()
 	f, err := os.CreateTemp(t.TempDir(), "grep-input-*.txt")
 	if err != nil {
@@ -168,6 +310,37 @@ func writeTempFile(t *testing.T, lines []string) string {
 	return f.Name()
 }
 
+// writeTree creates the given files, keyed by slash-separated relative path, under a temp dir
+// and returns its path.
+func writeTree(t *testing.T, files map[string][]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, lines := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	return root
+}
+
+// compareSortedOutputs compares outputs as sets of lines, for grep -r whose file order is
+// the unspecified directory order.
+func compareSortedOutputs(t *testing.T, got, want string) {
+	t.Helper()
+	sorted := func(s string) string {
+		lines := strings.Split(strings.TrimRight(s, "\n\r "), "\n")
+		slices.Sort(lines)
+		return strings.Join(lines, "\n")
+	}
+	if sorted(got) != sorted(want) {
+		t.Fatalf("output mismatch\n--- distributed ---\n%s\n--- system grep ---\n%s", got, want)
+	}
+}
+
 // compare trims trailing whitespace for robustness and compares equality.
 func compareOutputs(t *testing.T, got, want string) {
 	t.Helper()
@@ -254,3 +427,1349 @@ func TestRegex(t *testing.T) {
 	sysOut := runSystemGrep(t, "fo.", file)
 	compareOutputs(t, distOut, sysOut)
 }
+
+func TestLineNumbers(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	lines := []string{"foo", "bar", "foo bar", "baz", "food"}
+	file := writeTempFile(t, lines)
+
+	distOut := runClient(t, clientBin, "--addrs", strings.Join(addrs, ","), "-n", "--chunk-lines", "2", "foo", file)
+	sysOut := runSystemGrep(t, "-n", "foo", file)
+	compareOutputs(t, distOut, sysOut)
+}
+
+func TestContextAcrossChunks(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	lines := make([]string, 0, 40)
+	for i := range 40 {
+		if i%7 == 2 || i%11 == 0 {
+			lines = append(lines, fmt.Sprintf("match %d", i))
+		} else {
+			lines = append(lines, fmt.Sprintf("line %d", i))
+		}
+	}
+	file := writeTempFile(t, lines)
+
+	for _, args := range [][]string{
+		{"-C", "2", "--chunk-lines", "3"},
+		{"-n", "-C", "2", "--chunk-lines", "3"},
+		{"-A", "5", "--chunk-lines", "2"},
+		{"-B", "4", "--chunk-lines", "1"},
+		{"-v", "-C", "1", "--chunk-lines", "4"},
+	} {
+		grepArgs := append(append([]string{}, args[:len(args)-2]...), "match", file)
+		clientArgs := append(append([]string{"--addrs", strings.Join(addrs, ",")}, args...), "match", file)
+
+		distOut := runClient(t, clientBin, clientArgs...)
+		sysOut := runSystemGrep(t, grepArgs...)
+		compareOutputs(t, distOut, sysOut)
+	}
+}
+
+func TestLargeInputStreaming(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	lines := make([]string, 0, 50000)
+	for i := range 50000 {
+		lines = append(lines, fmt.Sprintf("request id=%d status=%d", i, 200+i%5))
+	}
+	file := writeTempFile(t, lines)
+
+	distOut := runClient(t, clientBin, "--addrs", strings.Join(addrs, ","), "--chunk-lines", "1000", "-B", "1", "status=203", file)
+	sysOut := runSystemGrep(t, "-B", "1", "status=203", file)
+	compareOutputs(t, distOut, sysOut)
+
+	distOut = runClient(t, clientBin, "--addrs", strings.Join(addrs, ","), "--chunk-lines", "1000", "-c", "status=203", file)
+	sysOut = runSystemGrep(t, "-c", "status=203", file)
+	compareOutputs(t, distOut, sysOut)
+}
+
+func TestRetryOnFailingServer(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 2)
+
+	lines := make([]string, 0, 30)
+	for i := range 30 {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	file := writeTempFile(t, lines)
+	sysOut := runSystemGrep(t, "-C", "1", "line 1", file)
+
+	// one failing server out of three: its chunks are reassigned
+	servers := []string{addrs[0], startFailingServer(t), addrs[1]}
+	distOut := runClient(t, clientBin, "--addrs", strings.Join(servers, ","), "--chunk-lines", "2", "--retry-backoff", "1ms", "-C", "1", "line 1", file)
+	compareOutputs(t, distOut, sysOut)
+
+	// a single healthy server is enough for complete output
+	servers = []string{startFailingServer(t), startFailingServer(t), addrs[0]}
+	distOut = runClient(t, clientBin, "--addrs", strings.Join(servers, ","), "--chunk-lines", "2", "--retries", "2", "--retry-backoff", "1ms", "-C", "1", "line 1", file)
+	compareOutputs(t, distOut, sysOut)
+
+	// a server that never answers is given up on after the timeout, like a failing one
+	servers = []string{startHangingServer(t), addrs[0]}
+	distOut, stderr, code := runClientStatus(t, clientBin, "--addrs", strings.Join(servers, ","), "--chunk-lines", "2", "--timeout", "200ms", "--retry-backoff", "1ms", "-C", "1", "line 1", file)
+	if code != 0 || !strings.Contains(stderr, "did not answer within 200ms") {
+		t.Errorf("expected exit code 0 and a timeout, got %d: %s", code, stderr)
+	}
+	compareOutputs(t, distOut, sysOut)
+	hanging := startHangingServer(t)
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", hanging, "--timeout", "200ms", "--retries", "1", "--retry-backoff", "1ms", "line 1", file); code != 2 || !strings.Contains(stderr, "did not answer") {
+		t.Errorf("expected exit code 2 and a timeout, got %d: %s", code, stderr)
+	}
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", hanging, "--remote", "--timeout", "200ms", "line 1", "app.log"); code != 2 || !strings.Contains(stderr, "did not answer") {
+		t.Errorf("expected exit code 2 and a timeout, got %d: %s", code, stderr)
+	}
+}
+
+func TestReplicaVoting(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 2)
+
+	lines := []string{"alpha", "beta", "gamma", "alphabet"}
+	file := writeTempFile(t, lines)
+	sysOut := runSystemGrep(t, "alpha", file)
+
+	servers := strings.Join([]string{addrs[0], startLyingServer(t), addrs[1]}, ",")
+
+	// two honest replicas outvote the lying one, whose disagreement still fails the run
+	out, stderr, code := runClientStatus(t, clientBin, "--addrs", servers, "--replicas", "3", "--quorum", "2", "alpha", file)
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d\n%s", code, stderr)
+	}
+	compareOutputs(t, out, sysOut)
+	if !strings.Contains(stderr, "1 of 3 answering replicas disagree") {
+		t.Errorf("expected the disagreement to be reported on stderr, got %q", stderr)
+	}
+
+	// replicas that agree succeed
+	out, stderr, code = runClientStatus(t, clientBin, "--addrs", strings.Join(addrs, ","), "--replicas", "2", "alpha", file)
+	if code != 0 || stderr != "" {
+		t.Errorf("expected exit code 0 and nothing on stderr, got %d: %s", code, stderr)
+	}
+	compareOutputs(t, out, sysOut)
+
+	// unanimity cannot be reached
+	_, stderr, code = runClientStatus(t, clientBin, "--addrs", servers, "--replicas", "3", "--quorum", "3", "alpha", file)
+	if code == 0 {
+		t.Errorf("expected non-zero exit code without quorum")
+	}
+	if !strings.Contains(stderr, "no quorum") {
+		t.Errorf("expected the missing quorum to be reported on stderr, got %q", stderr)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	file := writeTempFile(t, []string{"alpha", "beta", "gamma"})
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	for _, args := range [][]string{
+		{"alpha", file},
+		{"delta", file},
+		{"-c", "delta", file},
+		{"-v", "a", file},
+		{"alpha", missing},
+		{"alpha", missing, file},
+		{"a[", file},
+	} {
+		_, _, got := runClientStatus(t, clientBin, append([]string{"--addrs", strings.Join(addrs, ",")}, args...)...)
+		if want := runSystemGrepStatus(t, args...); got != want {
+			t.Errorf("grep %v: exit code %d, system grep %d", args, got, want)
+		}
+	}
+
+	// usage errors
+	if _, _, got := runClientStatus(t, clientBin, "--addrs", strings.Join(addrs, ",")); got != 2 {
+		t.Errorf("expected exit code 2 without a pattern, got %d", got)
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	root := writeTree(t, map[string][]string{
+		"a.log":            {"foo 1", "bar", "foo 2"},
+		"b.txt":            {"nothing here", "foo 3"},
+		"sub/c.log":        {"x", "foo 4", "y", "z", "foo 5"},
+		"sub/deep/d.txt":   {"foo 6"},
+		"skip/e.log":       {"foo 7"},
+		"sub/skip/f.log":   {"foo 8"},
+		"empty/nomatch.md": {"bar"},
+	})
+
+	for _, args := range [][]string{
+		{"-r", "foo", root},
+		{"-r", "-n", "-C", "1", "foo", root},
+		{"-r", "-c", "foo", root},
+		{"-r", "--include", "*.log", "foo", root},
+		{"-r", "--exclude", "*.txt", "--exclude-dir", "skip", "foo", root},
+		{"-r", "--include", "*.txt", "--include", "*.md", "-v", "foo", root},
+		{"-r", "foo", filepath.Join(root, "b.txt")},
+		{"-r", "foo", filepath.Join(root, "sub"), filepath.Join(root, "a.log")},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "2"}, args...)
+		distOut := runClient(t, clientBin, clientArgs...)
+		sysOut := runSystemGrep(t, args...)
+		compareSortedOutputs(t, distOut, sysOut)
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	a := writeTempFile(t, []string{"foo 1", "bar", "foo 2", "baz"})
+	b := writeTempFile(t, []string{"nothing here", "bar"})
+	c := writeTempFile(t, []string{"x", "y", "foo 3"})
+
+	for _, args := range [][]string{
+		{"foo", a, b, c},
+		{"-n", "-C", "1", "foo", a, b, c},
+		{"-c", "foo", a, b, c},
+		{"-H", "foo", a},
+		{"-H", "-c", "foo", a},
+		{"-h", "foo", a, b, c},
+		{"-h", "-n", "-A", "1", "foo", a, c},
+		{"-l", "foo", a, b, c},
+		{"-l", "-v", "foo", a, b, c},
+		{"-l", "-c", "foo", a, b, c},
+		{"-L", "foo", a, b, c},
+		{"-L", "foo", a},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "2"}, args...)
+		distOut, _, code := runClientStatus(t, clientBin, clientArgs...)
+		compareOutputs(t, distOut, runSystemGrep(t, args...))
+		if want := runSystemGrepStatus(t, args...); code != want {
+			t.Errorf("grep %v: exit code %d, want %d", args, code, want)
+		}
+	}
+}
+
+// TestListStopsEarly checks that -l stops searching a file once a match is found.
+func TestListStopsEarly(t *testing.T) {
+	_, clientBin := buildBinaries(t)
+
+	// every chunk matches; answer slowly so that unneeded chunks can still be cancelled
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		requests.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"found_blocks":[{"start_line_number":1,"lines":["1"]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("foo %d", i)
+	}
+	file := writeTempFile(t, lines)
+
+	out := runClient(t, clientBin, "--addrs", strings.TrimPrefix(srv.URL, "http://"), "--chunk-lines", "1", "-l", "foo", file)
+	compareOutputs(t, out, file+"\n")
+	if n := requests.Load(); n > 10 {
+		t.Errorf("searched %d of 1000 chunks after the first match", n)
+	}
+}
+
+func TestMultiplePatterns(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	lines := make([]string, 0, 2000)
+	for i := range 2000 {
+		lines = append(lines, fmt.Sprintf("user=u%04d action=%s", i, []string{"login", "Logout", "view"}[i%3]))
+	}
+	lines = append(lines, "abcx", "xbcx", "xcdex", "ab cd")
+	file := writeTempFile(t, lines)
+
+	// hundreds of IDs, some of them prefixes or suffixes of others
+	ids := make([]string, 0, 300)
+	for i := 0; i < 1500; i += 5 {
+		ids = append(ids, fmt.Sprintf("u%04d ", i), fmt.Sprintf("=u%03d", i/10))
+	}
+	idFile := writeTempFile(t, ids)
+	overlapping := writeTempFile(t, []string{"abcd", "bc", "cde"})
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-e", "login", "-e", "u000.", file},
+		{"-n", "-e", "Logout", "-e", "u19[0-9][0-9] action=view", file},
+		{"-i", "-e", "LOGOUT", "-e", "U0005", file},
+		{"-v", "-e", "login", "-e", "view", file},
+		{"-c", "-F", "-f", idFile, file},
+		{"-F", "-f", overlapping, file},
+		{"-F", "-i", "-e", "LOGIN", "-e", "U1999", file},
+		{"-f", overlapping, "-e", "u1234", file},
+		{"-f", empty, file},
+		{"-v", "-c", "-f", empty, file},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "300"}, args...)
+		distOut, _, code := runClientStatus(t, clientBin, clientArgs...)
+		compareOutputs(t, distOut, runSystemGrep(t, args...))
+		if want := runSystemGrepStatus(t, args...); code != want {
+			t.Errorf("grep %v: exit code %d, want %d", args, code, want)
+		}
+	}
+
+	// pattern sets above the size accepted by the servers are rejected rather than retried
+	many := make([]string, 0, 40000)
+	for i := range cap(many) {
+		many = append(many, fmt.Sprintf("%08x-0000-4000-8000-%012x", i, i))
+	}
+	manyFile := writeTempFile(t, many)
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", strings.Join(addrs, ","), "-F", "-f", manyFile, file); code != 2 || !strings.Contains(stderr, "patterns too large") {
+		t.Errorf("expected exit code 2 and patterns too large, got %d: %s", code, stderr)
+	}
+}
+
+func TestOnlyMatchingAndColor(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	a := writeTempFile(t, []string{"foo bar foo", "baz", "qux foofoo", "none", "x", "y", "Foo", "abcdef ab"})
+	b := writeTempFile(t, []string{"afoo", "nothing"})
+
+	for _, args := range [][]string{
+		{"-o", "foo", a},
+		{"-o", "-n", "-C", "1", "foo", a, b},
+		{"-o", "-v", "-C", "1", "foo", a},
+		{"-o", "-i", "-F", "FOO", a},
+		{"-o", "-e", "ab", "-e", "abcd", a},
+		{"-o", "o*", a},
+		{"--color=always", "foo", a},
+		{"--color=always", "-n", "-C", "1", "foo", a, b},
+		{"--color=always", "-v", "-A", "1", "baz", a},
+		{"--color=always", "-i", "-F", "-e", "FOO", "-e", "ab", a},
+		{"--color=always", "-o", "-n", "foo", a, b},
+		{"--color=always", "-c", "foo", a, b},
+		{"--color=always", "-l", "foo", a, b},
+		{"--color=never", "foo", a},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "3"}, args...)
+		compareOutputs(t, runClient(t, clientBin, clientArgs...), runSystemGrep(t, args...))
+	}
+
+	// colors are taken from GREP_COLORS, which both greps inherit
+	t.Setenv("GREP_COLORS", "ms=04;32:mc=01;34:fn=33:ln=34:se=35")
+	for _, args := range [][]string{
+		{"--color=always", "-n", "-H", "-C", "1", "baz", a},
+		{"--color=always", "-v", "-A", "1", "baz", a},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ",")}, args...)
+		compareOutputs(t, runClient(t, clientBin, clientArgs...), runSystemGrep(t, args...))
+	}
+}
+
+func TestWordAndLineMatch(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	file := writeTempFile(t, []string{
+		"foo",
+		"foobar",
+		"a foo b",
+		"foo_bar foo-bar",
+		"barfoo foo",
+		"Foo",
+		"-foo-",
+		"x foo.bar",
+		"foofoo foo foo",
+		"",
+	})
+
+	for _, args := range [][]string{
+		{"-w", "foo", file},
+		{"-w", "-n", "-o", "foo", file},
+		{"-w", "-i", "foo", file},
+		{"-w", "-v", "foo", file},
+		{"-w", "fo*", file},
+		{"-w", "-o", "-e", "foo", "-e", "bar", file},
+		{"-w", "--color=always", "foo", file},
+		{"-w", "-F", "foo.bar", file},
+		{"-w", "-F", "-i", "-o", "-e", "FOO", "-e", "-foo-", file},
+		{"-x", "foo", file},
+		{"-x", "-i", "foo", file},
+		{"-x", "-c", "-v", "foo", file},
+		{"-x", "fo*", file},
+		{"-x", "-e", "foo", "-e", "-foo-", "-e", "", file},
+		{"-x", "-F", "foo.bar", file},
+		{"-x", "-F", "-i", "-e", "FOO", "-e", "foobar", file},
+		{"-x", "-o", "--color=always", "foo", file},
+		{"-x", "-w", "foo", file},
+	} {
+		clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "4"}, args...)
+		distOut, _, code := runClientStatus(t, clientBin, clientArgs...)
+		compareOutputs(t, distOut, runSystemGrep(t, args...))
+		if want := runSystemGrepStatus(t, args...); code != want {
+			t.Errorf("grep %v: exit code %d, want %d", args, code, want)
+		}
+	}
+}
+
+func TestMaxCount(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	a := writeTempFile(t, []string{"a1", "b", "a2", "a3", "b", "b", "a4", "b", "a5", "a6"})
+	b := writeTempFile(t, []string{"b", "a7", "b"})
+
+	for _, args := range [][]string{
+		{"-m", "2", "a", a},
+		{"-m", "2", "-n", "-A", "2", "a", a},
+		{"-m", "1", "-n", "-A", "3", "a", a},
+		{"-m", "2", "-n", "-C", "1", "a", a, b},
+		{"-m", "3", "-v", "-n", "-A", "1", "a", a},
+		{"-m", "2", "-c", "a", a, b},
+		{"-m", "4", "-c", "a", a},
+		{"-m", "5", "-c", "-v", "a", a},
+		{"-m", "2", "-o", "--color=always", "-A", "2", "a", a},
+		{"-m", "1", "-l", "a", a, b},
+		{"-m", "100", "a", a},
+		{"-m", "-1", "a", a},
+		{"-m", "0", "a", a},
+		{"-m", "0", "-c", "a", a},
+	} {
+		for _, chunk := range []string{"1", "2", "3", "100"} {
+			clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", chunk}, args...)
+			distOut, _, code := runClientStatus(t, clientBin, clientArgs...)
+			compareOutputs(t, distOut, runSystemGrep(t, args...))
+			if want := runSystemGrepStatus(t, args...); code != want {
+				t.Errorf("grep %v: exit code %d, want %d", args, code, want)
+			}
+		}
+	}
+}
+
+// TestMaxCountStopsEarly checks that -m stops searching a file once enough lines were selected.
+func TestMaxCountStopsEarly(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 1)
+
+	// count the requests passing through to the real server, slowing them down
+	// so that unneeded chunks can still be cancelled
+	var requests atomic.Int64
+	target := "http://" + addrs[0]
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/grep" {
+			requests.Add(1)
+			time.Sleep(20 * time.Millisecond)
+		}
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, target+r.URL.Path, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		req.Header = r.Header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	file := writeTempFile(t, lines)
+
+	args := []string{"-m", "3", "-n", "-A", "1", "line", file}
+	clientArgs := append([]string{"--addrs", strings.TrimPrefix(srv.URL, "http://"), "--chunk-lines", "2"}, args...)
+	compareOutputs(t, runClient(t, clientBin, clientArgs...), runSystemGrep(t, args...))
+	if n := requests.Load(); n > 10 {
+		t.Errorf("searched %d of 500 chunks for 3 lines", n)
+	}
+}
+
+// compressFixture writes data compressed with the given format next to the plain file
+// and returns the path of the compressed copy.
+func compressFixture(t *testing.T, plain, format string) string {
+	t.Helper()
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "gz":
+		// two members, as left by appending to a .gz file
+		half := len(data) / 2
+		for _, part := range [][]byte{data[:half], data[half:]} {
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(part); err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+		}
+	case "zst":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		if _, err := zw.Write(data); err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+	case "bz2":
+		// the standard library only decompresses bzip2
+		if _, err := exec.LookPath("bzip2"); err != nil {
+			t.Skip("bzip2 not installed")
+		}
+		cmd := exec.Command("bzip2", "-c", plain)
+		cmd.Stdout = &buf
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("bzip2: %v", err)
+		}
+	}
+	path := plain + "." + format
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	return path
+}
+
+func TestCompressedInput(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+
+	lines := make([]string, 0, 5000)
+	for i := range 5000 {
+		lines = append(lines, fmt.Sprintf("request id=%d status=%d", i, 200+i%7))
+	}
+	plain := writeTempFile(t, lines)
+
+	for _, format := range []string{"gz", "zst", "bz2"} {
+		t.Run(format, func(t *testing.T) {
+			compressed := compressFixture(t, plain, format)
+			for _, args := range [][]string{
+				{"-n", "-C", "1", "id=4[0-9]*7 "},
+				{"-c", "status=203"},
+				{"-z", "-m", "5", "status=206"},
+			} {
+				clientArgs := append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "700"}, args...)
+				distOut := runClient(t, clientBin, append(clientArgs, compressed)...)
+				sysArgs := slices.DeleteFunc(slices.Clone(args), func(a string) bool { return a == "-z" })
+				compareOutputs(t, distOut, runSystemGrep(t, append(sysArgs, plain)...))
+			}
+		})
+	}
+
+	// -z refuses inputs that are not compressed, but still searches the others
+	gz := compressFixture(t, plain, "gz")
+	out, stderr, code := runClientStatus(t, clientBin, "--addrs", strings.Join(addrs, ","), "-z", "-c", "-H", "status=203", plain, gz)
+	if code != 2 || !strings.Contains(stderr, plain+": not in gzip, bzip2 or zstd format") {
+		t.Errorf("expected exit code 2 and an error for the plain file, got %d: %s", code, stderr)
+	}
+	if want := gz + ":" + strings.TrimSpace(runSystemGrep(t, "-c", "status=203", plain)); strings.TrimSpace(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+
+	// text starting like a magic number, but without a valid header, is searched as text
+	for _, head := range []string{"BZhello world", "BZh9 text", "\x1f\x8bhello world", "(\xb5/\xfd\xffhello world"} {
+		text := writeTempFile(t, []string{head, "status=203"})
+		for _, args := range [][]string{{"-c", "hello"}, {"status=203"}} {
+			out, stderr, code := runClientStatus(t, clientBin, append([]string{"--addrs", strings.Join(addrs, ",")}, append(args, text)...)...)
+			want, wantCode := runSystemGrep(t, append(args, text)...), runSystemGrepStatus(t, append(args, text)...)
+			if code != wantCode || out != want {
+				t.Errorf("%q: expected exit code %d and %q, got %d: %q %s", head, wantCode, want, code, out, stderr)
+			}
+		}
+	}
+
+	// corrupted data is an input error
+	data, err := os.ReadFile(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.gz")
+	if err := os.WriteFile(truncated, data[:len(data)/3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", strings.Join(addrs, ","), "status", truncated); code != 2 || !strings.Contains(stderr, truncated) {
+		t.Errorf("expected exit code 2 and an error for the truncated file, got %d: %s", code, stderr)
+	}
+}
+
+func TestBinaryFiles(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 3)
+	dist := []string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "50"}
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	var text strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&text, "record %d value=%d\n", i, i%11)
+	}
+	// a NUL byte in the middle of the input makes it binary from the start,
+	// one far past the first buffer only once it is reached
+	early := write("early.bin", text.String()[:2000]+"\x00"+text.String()[2000:])
+	late := write("late.bin", strings.Repeat(text.String(), 40)+"value=3\x00\n")
+
+	for _, tc := range []struct {
+		args  []string
+		input string
+	}{
+		{[]string{"-n", "value=3"}, early},
+		{[]string{"-c", "value=3"}, early},
+		{[]string{"-l", "value=3"}, early},
+		{[]string{"-n", "-a", "value=3"}, early},
+		{[]string{"-n", "--binary-files=text", "value=3"}, early},
+		{[]string{"-I", "value=3"}, early},
+		{[]string{"-I", "-c", "value=3"}, early},
+		{[]string{"-I", "-L", "value=3"}, early},
+		{[]string{"-I", "-c", "value=3"}, late},
+		{[]string{"-n", "no such value"}, early},
+	} {
+		args := append(slices.Clone(tc.args), tc.input)
+		distOut, distErr, distCode := runClientStatus(t, clientBin, append(dist, args...)...)
+
+		cmd := exec.Command("grep", args...)
+		var sysErr strings.Builder
+		cmd.Stderr = &sysErr
+		sysOut, err := cmd.Output()
+		sysCode := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			sysCode = exitErr.ExitCode()
+		}
+
+		if distOut != string(sysOut) || distCode != sysCode {
+			t.Errorf("%v: got exit code %d and %d bytes, want %d and %d bytes", tc.args, distCode, len(distOut), sysCode, len(sysOut))
+		}
+		if want := strings.Contains(sysErr.String(), "binary file matches"); strings.Contains(distErr, tc.input+": binary file matches") != want {
+			t.Errorf("%v: got stderr %q, want binary file note: %v", tc.args, distErr, want)
+		}
+	}
+
+	// binary data found late stops the output where it is found; where exactly depends
+	// on chunk and buffer sizes, so only the lines printed are checked
+	full := runSystemGrep(t, "-a", "-n", "value=3", late)
+	for _, args := range [][]string{{"-n"}, {"-n", "--binary-files=without-match"}} {
+		out, stderr, code := runClientStatus(t, clientBin, append(dist, append(args, "value=3", late)...)...)
+		if out == "" || !strings.HasPrefix(full, out) || len(out) == len(full) {
+			t.Errorf("%v: expected the lines before the binary data, got %d of %d bytes", args, len(out), len(full))
+		}
+		if args[len(args)-1] == "-n" && (code != 0 || !strings.Contains(stderr, late+": binary file matches")) {
+			t.Errorf("%v: expected exit code 0 and a binary file note, got %d: %s", args, code, stderr)
+		}
+		if args[len(args)-1] != "-n" && (code != 1 || stderr != "") {
+			t.Errorf("%v: expected exit code 1 and no note, got %d: %s", args, code, stderr)
+		}
+	}
+
+	if _, stderr, code := runClientStatus(t, clientBin, append(dist, "--binary-files=maybe", "x", early)...); code != 2 || stderr == "" {
+		t.Errorf("expected exit code 2 for an invalid --binary-files value, got %d: %s", code, stderr)
+	}
+}
+
+func TestByteExactLines(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 2)
+
+	// bytes that are not UTF-8, carriage returns and lines longer than any buffer
+	// must come back exactly as they were read
+	long := strings.Repeat("abcdefghij", 20000)
+	lines := []string{
+		"plain line",
+		"latin-1 caf\xe9 match",
+		"stray \xff\xfe bytes match",
+		"windows line match\r",
+		long + " match " + long,
+		"last line",
+	}
+	input := writeTempFile(t, lines)
+
+	for _, args := range [][]string{
+		{"-n", "match"},
+		{"-v", "-n", "match"},
+		{"-o", "-e", "caf.", "-e", "match"},
+		{"-c", "match"},
+	} {
+		distOut := runClient(t, clientBin, append([]string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "2"}, append(args, input)...)...)
+		sysOut := runSystemGrep(t, append(args, input)...)
+		if distOut != sysOut {
+			t.Errorf("%v: output differs from grep (%d vs %d bytes)", args, len(distOut), len(sysOut))
+		}
+	}
+}
+
+func TestRemoteFiles(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+
+	// the servers share one root here; in a deployment each holds its own copy
+	root := t.TempDir()
+	var app, other strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&app, "request id=%d status=%d\n", i, 200+i%7)
+	}
+	for i := range 300 {
+		fmt.Fprintf(&other, "job %d status=%d\n", i, 200+i%5)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "logs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"app.log":       app.String(),
+		"logs/jobs.log": strings.TrimSuffix(other.String(), "\n"), // no final newline
+		"data.bin":      "status=203\x00\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, addrs := startServers(t, serverBin, 3, "-root="+root)
+
+	// system grep runs in the root, so that files are named the same way
+	systemGrep := func(args ...string) (string, string, int) {
+		cmd := exec.Command("grep", args...)
+		cmd.Dir = root
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		}
+		return string(out), stderr.String(), code
+	}
+
+	for _, args := range [][]string{
+		{"-n", "id=4[0-9]*7 ", "app.log"},
+		{"-n", "-C", "2", "status=203", "app.log"},
+		{"-v", "-c", "status=20[0-5]", "app.log"},
+		{"-n", "-m", "5", "-A", "3", "status=206", "app.log", "logs/jobs.log"},
+		{"-n", "-B", "2500", "-m", "1", "id=3000 "},
+		{"-o", "-n", "job [0-9]*0 ", "logs/jobs.log"},
+		{"-l", "status=204", "app.log", "logs/jobs.log", "data.bin"},
+		{"-n", "status=203", "data.bin", "logs/jobs.log"},
+	} {
+		if args[len(args)-1] == "id=3000 " {
+			args = append(args, "app.log")
+		}
+		for _, chunk := range []string{"1000", "100000"} {
+			distOut, distErr, distCode := runClientStatus(t, clientBin, append([]string{"--addrs", strings.Join(addrs, ","), "--remote", "--chunk-bytes", chunk}, args...)...)
+			sysOut, sysErr, sysCode := systemGrep(args...)
+			if distOut != sysOut || distCode != sysCode {
+				t.Errorf("%v with --chunk-bytes %s: got exit code %d, want %d", args, chunk, distCode, sysCode)
+				compareOutputs(t, distOut, sysOut)
+			}
+			if strings.Contains(sysErr, "binary file matches") != strings.Contains(distErr, "data.bin: binary file matches") {
+				t.Errorf("%v with --chunk-bytes %s: got stderr %q, want %q", args, chunk, distErr, sysErr)
+			}
+		}
+	}
+
+	// files outside the root, missing files and local-only inputs are errors
+	for _, args := range [][]string{
+		{"status", "missing.log"},
+		{"status", "../outside.log"},
+		{"status", root + "/app.log"},
+		{"status"},
+		{"-r", "status", "logs"},
+	} {
+		if _, stderr, code := runClientStatus(t, clientBin, append([]string{"--addrs", strings.Join(addrs, ","), "--remote"}, args...)...); code != 2 || stderr == "" {
+			t.Errorf("%v: expected exit code 2 and an error, got %d: %s", args, code, stderr)
+		}
+	}
+
+	// servers holding different copies, such as shards, are refused rather than searched
+	shard := t.TempDir()
+	if err := os.WriteFile(filepath.Join(shard, "app.log"), []byte(app.String()[:app.Len()/2]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, shardAddrs := startServers(t, serverBin, 1, "-root="+shard)
+	mixed := strings.Join(append(slices.Clone(addrs), shardAddrs...), ",")
+	if out, stderr, code := runClientStatus(t, clientBin, "--addrs", mixed, "--remote", "-c", "status", "app.log"); code != 2 || out != "" || !strings.Contains(stderr, "different copies") {
+		t.Errorf("expected exit code 2 and different copies reported, got %d: %q %s", code, out, stderr)
+	}
+	// unless each server is said to hold a shard: every shard is then searched whole and
+	// reported under the name of its server, in the order of the servers
+	rest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rest, "app.log"), []byte(app.String()[app.Len()/2:]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, restAddrs := startServers(t, serverBin, 1, "-root="+rest)
+	shards := []string{shardAddrs[0], restAddrs[0]}
+	for _, args := range [][]string{
+		{"-n", "status=203", "app.log"},
+		{"-c", "-v", "id=1", "app.log"},
+	} {
+		var want strings.Builder
+		for i, dir := range []string{shard, rest} {
+			cmd := exec.Command("grep", args...)
+			cmd.Dir = dir
+			out, _ := cmd.Output()
+			for line := range strings.Lines(string(out)) {
+				want.WriteString("app.log@" + shards[i] + ":" + line)
+			}
+		}
+		out, stderr, code := runClientStatus(t, clientBin, append([]string{"--addrs", strings.Join(shards, ","), "--remote", "--shards", "--chunk-bytes", "5000"}, args...)...)
+		if code != 0 {
+			t.Errorf("%v with --shards: exit code %d: %s", args, code, stderr)
+		}
+		compareOutputs(t, out, want.String())
+	}
+	// a shard that cannot be searched fails the run rather than being left out
+	down := fmt.Sprintf("127.0.0.1:%d", getFreePort(t))
+	for _, args := range [][]string{
+		{"--addrs", strings.Join(append(shards, down), ","), "--remote", "--shards", "status", "app.log"},
+		{"--addrs", strings.Join(shards, ","), "--shards", "status", "app.log"},
+	} {
+		if out, stderr, code := runClientStatus(t, clientBin, args...); code != 2 || out != "" || stderr == "" {
+			t.Errorf("%v: expected exit code 2 and an error, got %d: %q %s", args, code, out, stderr)
+		}
+	}
+
+	// and a copy that changed since the client asked for its size rejects the byte ranges
+	body := fmt.Sprintf(`{"patterns":["status"],"path":"app.log","offset":0,"length":100,"size":%d}`, app.Len())
+	resp, err := http.Post("http://"+shardAddrs[0]+"/grep", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for a byte range of a file of another size, got %d", resp.StatusCode)
+	}
+
+	// servers started without -root refuse to read files
+	_, plain := startServers(t, serverBin, 1)
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", plain[0], "--remote", "status", "app.log"); code != 2 || !strings.Contains(stderr, "file access is not enabled") {
+		t.Errorf("expected exit code 2 and file access refused, got %d: %s", code, stderr)
+	}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	root := t.TempDir()
+	grpcAddrs := startGRPCServers(t, serverBin, 2, "-root="+root)
+	_, httpAddrs := startServers(t, serverBin, 1, "-root="+root)
+
+	lines := make([]string, 0, 3000)
+	for i := range 3000 {
+		lines = append(lines, fmt.Sprintf("request id=%d status=%d", i, 200+i%7))
+	}
+	lines = append(lines, "caf\xe9 status=203", strings.Repeat("x", 100000)+" status=203")
+	input := writeTempFile(t, lines)
+	if err := os.WriteFile(filepath.Join(root, "app.log"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// gRPC servers alone, and mixed with a JSON one
+	for _, addrs := range [][]string{grpcAddrs, append(slices.Clone(grpcAddrs), httpAddrs...)} {
+		for _, args := range [][]string{
+			{"-n", "-C", "2", "status=203"},
+			{"-c", "-v", "status=20[0-4]"},
+			{"-o", "-n", "-m", "10", "id=[0-9]*5 "},
+			{"-w", "-n", "-e", "caf.", "-e", "id=7"},
+		} {
+			base := []string{"--addrs", strings.Join(addrs, ","), "--chunk-lines", "400"}
+			distOut := runClient(t, clientBin, append(base, append(args, input)...)...)
+			compareOutputs(t, distOut, runSystemGrep(t, append(args, input)...))
+
+			remoteOut := runClient(t, clientBin, append(base, append([]string{"--remote", "--chunk-bytes", "20000"}, append(args, "app.log")...)...)...)
+			compareOutputs(t, remoteOut, distOut)
+		}
+	}
+
+	// an empty pattern list selects no line rather than being taken for a missing pattern
+	empty := writeTempFile(t, nil)
+	if out, stderr, code := runClientStatus(t, clientBin, "--addrs", grpcAddrs[0], "-f", empty, input); code != 1 || out != "" {
+		t.Errorf("expected exit code 1 and no output for no patterns, got %d: %q %s", code, out, stderr)
+	}
+	// requests rejected by the server are not retried
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", strings.Join(grpcAddrs, ","), "a(b", input); code != 2 || !strings.Contains(stderr, "invalid regex") || strings.Count(stderr, "invalid regex") > 2 {
+		t.Errorf("expected exit code 2 and one invalid regex error, got %d: %s", code, stderr)
+	}
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", grpcAddrs[0], "--remote", "x", "missing.log"); code != 2 || !strings.Contains(stderr, "no such file") {
+		t.Errorf("expected exit code 2 and no such file, got %d: %s", code, stderr)
+	}
+}
+
+// BenchmarkTransports compares searching a file through JSON and gRPC servers,
+// with many lines selected so that results weigh as much as tasks.
+func BenchmarkTransports(b *testing.B) {
+	serverBin, clientBin := buildBinaries(b)
+	lines := make([]string, 0, 300000)
+	for i := range 300000 {
+		lines = append(lines, fmt.Sprintf("2024-05-01T12:%02d:%02d request id=%d status=%d path=/api/v1/items/%d", i/60%60, i%60, i, 200+i%7, i%977))
+	}
+	input := writeTempFile(b, lines)
+
+	_, httpAddrs := startServers(b, serverBin, 3)
+	grpcAddrs := startGRPCServers(b, serverBin, 3)
+	for _, bc := range []struct {
+		name  string
+		addrs []string
+	}{
+		{"json", httpAddrs},
+		{"grpc", grpcAddrs},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for b.Loop() {
+				cmd := exec.Command(clientBin, "--addrs", strings.Join(bc.addrs, ","), "-n", "status=20[0-3]", input)
+				cmd.Stdout = io.Discard
+				if err := cmd.Run(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressedTransport(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	_, addrs := startServers(t, serverBin, 2)
+	// a server that only speaks uncompressed JSON, like servers before compression
+	plain := startPlainServer(t, serverBin)
+
+	lines := make([]string, 0, 5000)
+	for i := range 5000 {
+		lines = append(lines, fmt.Sprintf("request id=%d status=%d", i, 200+i%7))
+	}
+	lines = append(lines, "caf\xe9 status=203")
+	input := writeTempFile(t, lines)
+
+	for _, framing := range []string{"json", "protobuf"} {
+		for _, coding := range []string{"none", "gzip", "zstd"} {
+			for _, servers := range [][]string{addrs, {plain}} {
+				for _, args := range [][]string{
+					{"-n", "-C", "1", "status=203"},
+					{"-c", "status=20[0-4]"},
+					{"-o", "-n", "caf. status"},
+				} {
+					distOut := runClient(t, clientBin, append([]string{"--addrs", strings.Join(servers, ","), "--chunk-lines", "700", "--compress", coding, "--framing", framing}, append(args, input)...)...)
+					compareOutputs(t, distOut, runSystemGrep(t, append(args, input)...))
+				}
+			}
+		}
+	}
+
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", addrs[0], "--compress", "lz4", "x", input); code != 2 || stderr == "" {
+		t.Errorf("expected exit code 2 for an invalid --compress value, got %d: %s", code, stderr)
+	}
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", addrs[0], "--framing", "xml", "x", input); code != 2 || stderr == "" {
+		t.Errorf("expected exit code 2 for an invalid --framing value, got %d: %s", code, stderr)
+	}
+	// errors are reported the same way whatever the framing
+	if _, stderr, code := runClientStatus(t, clientBin, "--addrs", addrs[0], "--framing", "protobuf", "a(b", input); code != 2 || !strings.Contains(stderr, "invalid regex") {
+		t.Errorf("expected exit code 2 and an invalid regex error, got %d: %s", code, stderr)
+	}
+	resp, err := http.Post("http://"+addrs[0]+"/grep", "application/x-protobuf", strings.NewReader("\xff\xff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a malformed protobuf task, got %d", resp.StatusCode)
+	}
+
+	// a small body decompressing to gigabytes is refused rather than buffered
+	var bomb bytes.Buffer
+	enc, err := zstd.NewWriter(&bomb, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an endless JSON string, so that decoding goes on until the limit
+	if _, err := io.CopyN(enc, io.MultiReader(strings.NewReader(`{"lines":["`), repeated('a')), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://"+addrs[0]+"/grep", &bomb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "zstd")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for a decompression bomb, got %d", resp.StatusCode)
+	}
+}
+
+// repeated is an endless reader of one byte.
+type repeated byte
+
+func (r repeated) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+// startPlainServer starts a proxy to a real server that rejects compressed requests
+// with 415 and never compresses responses, and returns its address.
+func startPlainServer(t *testing.T, serverBin string) string {
+	t.Helper()
+	_, addrs := startServers(t, serverBin, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, "http://"+addrs[0]+r.URL.RequestURI(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		req.Header.Set("Accept-Encoding", "identity")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+			http.Error(w, "unexpected content encoding "+enc, http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// BenchmarkCompression compares the throughput of searching a file over HTTP with
+// uncompressed, gzip and zstd bodies, on loopback and through a shared 100 Mbit/s link.
+// Few lines are selected by the count workload, where tasks dominate, and many by
+// the lines workload, where results weigh as much as tasks.
+func BenchmarkCompression(b *testing.B) {
+	serverBin, clientBin := buildBinaries(b)
+	lines := make([]string, 0, 300000)
+	for i := range 300000 {
+		lines = append(lines, fmt.Sprintf("2024-05-01T12:%02d:%02d request id=%d status=%d path=/api/v1/items/%d", i/60%60, i%60, i, 200+i%7, i%977))
+	}
+	input := writeTempFile(b, lines)
+	info, err := os.Stat(input)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, addrs := startServers(b, serverBin, 3)
+	// the client reaches all servers through one link
+	link := &throttledLink{up: linkLimiter{rate: 100 << 20 / 8}, down: linkLimiter{rate: 100 << 20 / 8}}
+	slow := make([]string, len(addrs))
+	for i, addr := range addrs {
+		slow[i] = startThrottledProxy(b, addr, link)
+	}
+
+	for _, link := range []struct {
+		name  string
+		addrs []string
+	}{
+		{"loopback", addrs},
+		{"100Mbit", slow},
+	} {
+		for _, workload := range []struct {
+			name string
+			args []string
+		}{
+			{"count", []string{"-c", "status=203"}},
+			{"lines", []string{"-n", "status=20[0-3]"}},
+		} {
+			for _, framing := range []string{"json", "protobuf"} {
+				for _, coding := range []string{"none", "gzip", "zstd"} {
+					b.Run(link.name+"/"+workload.name+"/"+framing+"/"+coding, func(b *testing.B) {
+						b.SetBytes(info.Size())
+						args := append([]string{"--addrs", strings.Join(link.addrs, ","), "--framing", framing, "--compress", coding}, append(workload.args, input)...)
+						for b.Loop() {
+							cmd := exec.Command(clientBin, args...)
+							cmd.Stdout = io.Discard
+							if err := cmd.Run(); err != nil {
+								b.Fatal(err)
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// throttledLink limits the traffic in each direction of the connections through it
+type throttledLink struct {
+	up, down linkLimiter
+}
+
+// startThrottledProxy forwards TCP connections to addr through the link and returns its address.
+func startThrottledProxy(t testing.TB, addr string, link *throttledLink) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			client, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				server, err := net.Dial("tcp", addr)
+				if err != nil {
+					client.Close()
+					return
+				}
+				go link.up.copy(server, client)
+				link.down.copy(client, server)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// linkLimiter delays writes so that they add up to at most rate bytes per second
+type linkLimiter struct {
+	rate int
+	mu   sync.Mutex
+	next time.Time // when the link is free again
+}
+
+// copy copies src to dst through the limiter, closing both when either side is done
+func (l *linkLimiter) copy(dst, src net.Conn) {
+	defer dst.Close()
+	defer src.Close()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			l.mu.Lock()
+			now := time.Now()
+			l.next = maxTime(l.next, now).Add(time.Duration(n) * time.Second / time.Duration(l.rate))
+			wait := l.next.Sub(now)
+			l.mu.Unlock()
+			time.Sleep(wait)
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// testPKI holds the files of a throwaway CA and of a server and a client certificate it signed.
+type testPKI struct {
+	caCert     string // CA certificate
+	serverCert string // certificate for 127.0.0.1 and localhost
+	serverKey  string
+	clientCert string // client certificate followed by its key, in one file
+	roots      *x509.CertPool
+	client     tls.Certificate
+}
+
+// writePKI generates a CA and certificates signed by it into a temporary directory.
+func writePKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name string, blocks ...*pem.Block) string {
+		var buf bytes.Buffer
+		for _, b := range blocks {
+			if err := pem.Encode(&buf, b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	issue := func(tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, *pem.Block, *pem.Block) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key, &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+	}
+
+	ca, caKey, caPEM, _ := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "distgrep test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	_, _, serverPEM, serverKeyPEM := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "distgrep server"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	_, _, clientPEM, clientKeyPEM := issue(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "distgrep client"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	client, err := tls.X509KeyPair(pem.EncodeToMemory(clientPEM), pem.EncodeToMemory(clientKeyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return testPKI{
+		caCert:     write("ca.pem", caPEM),
+		serverCert: write("server.pem", serverPEM),
+		serverKey:  write("server-key.pem", serverKeyPEM),
+		clientCert: write("client.pem", clientPEM, clientKeyPEM),
+		roots:      roots,
+		client:     client,
+	}
+}
+
+// httpsClient returns an HTTP client trusting the test CA and presenting its client certificate.
+func (p testPKI) httpsClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      p.roots,
+		Certificates: []tls.Certificate{p.client},
+	}}}
+}
+
+func TestSecureTransport(t *testing.T) {
+	serverBin, clientBin := buildBinaries(t)
+	pki := writePKI(t)
+	root := t.TempDir()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make([]string, 0, 2000)
+	for i := range 2000 {
+		lines = append(lines, fmt.Sprintf("request id=%d status=%d", i, 200+i%7))
+	}
+	input := writeTempFile(t, lines)
+	if err := os.WriteFile(filepath.Join(root, "app.log"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-n", "-C", "1", "status=203"}
+	want := runSystemGrep(t, append(args, input)...)
+
+	tlsArgs := []string{"-root=" + root, "-tls-cert=" + pki.serverCert, "-tls-key=" + pki.serverKey}
+	_, tlsAddrs := startServersWith(t, serverBin, 2, "https", pki.httpsClient(), tlsArgs...)
+	_, mtlsAddrs := startServersWith(t, serverBin, 1, "https", pki.httpsClient(), append(tlsArgs, "-client-ca="+pki.caCert)...)
+	_, tokenAddrs := startServers(t, serverBin, 1, "-token-file="+tokenFile)
+	grpcPort := getFreePort(t)
+	startServersWith(t, serverBin, 1, "https", pki.httpsClient(), append(tlsArgs, "-client-ca="+pki.caCert, "-token-file="+tokenFile, fmt.Sprintf("-grpc-port=%d", grpcPort))...)
+	grpcsAddr := fmt.Sprintf("127.0.0.1:%d", grpcPort)
+	waitListening(t, grpcsAddr)
+
+	https := "https://" + tlsAddrs[0] + ",https://" + tlsAddrs[1]
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{"https", []string{"--addrs", https, "--ca-cert", pki.caCert}},
+		{"mutual TLS", []string{"--addrs", "https://" + mtlsAddrs[0], "--ca-cert", pki.caCert, "--cert", pki.clientCert}},
+		{"token", []string{"--addrs", tokenAddrs[0], "--token", "s3cret", "--insecure-token"}},
+		{"grpcs", []string{"--addrs", "grpcs://" + grpcsAddr, "--ca-cert", pki.caCert, "--cert", pki.clientCert, "--token", "s3cret"}},
+	} {
+		out := runClient(t, clientBin, append(append(c.args, "--chunk-lines", "300"), append(args, input)...)...)
+		if out != want {
+			t.Errorf("%s: output differs from grep:\n%s", c.name, out)
+		}
+	}
+	remoteOut := runClient(t, clientBin, append([]string{"--addrs", "grpcs://" + grpcsAddr + "," + https, "--ca-cert", pki.caCert, "--cert", pki.clientCert,
+		"--token", "s3cret", "--remote", "--chunk-bytes", "10000"}, append(args, "app.log")...)...)
+	compareOutputs(t, remoteOut, want)
+
+	// the token is also read from the environment
+	t.Setenv("DISTGREP_TOKEN", "s3cret")
+	compareOutputs(t, runClient(t, clientBin, append([]string{"--addrs", tokenAddrs[0], "--insecure-token"}, append(args, input)...)...), want)
+	t.Setenv("DISTGREP_TOKEN", "")
+
+	for _, c := range []struct {
+		name   string
+		args   []string
+		stderr string
+	}{
+		// servers whose certificates cannot be verified fail their health checks
+		{"unknown CA", []string{"--addrs", https}, "no alive servers"},
+		{"no client certificate", []string{"--addrs", "https://" + mtlsAddrs[0], "--ca-cert", pki.caCert}, "no alive servers"},
+		// health checks stay open, while searches are rejected and not retried
+		{"no token", []string{"--addrs", tokenAddrs[0]}, "bearer token"},
+		{"wrong token", []string{"--addrs", tokenAddrs[0], "--token", "guess", "--insecure-token"}, "bearer token"},
+		// the token is not sent in plain text unless allowed
+		{"token over http", []string{"--addrs", tokenAddrs[0], "--token", "s3cret"}, "refusing to send the token"},
+		{"token over grpc", []string{"--addrs", "grpc://" + grpcsAddr, "--token", "s3cret"}, "refusing to send the token"},
+		{"grpcs without token", []string{"--addrs", "grpcs://" + grpcsAddr, "--ca-cert", pki.caCert, "--cert", pki.clientCert}, "bearer token"},
+		{"unsupported scheme", []string{"--addrs", "ftp://" + tokenAddrs[0]}, "unsupported scheme"},
+		{"missing CA file", []string{"--addrs", https, "--ca-cert", filepath.Join(root, "missing.pem")}, "no such file"},
+	} {
+		out, stderr, code := runClientStatus(t, clientBin, append(c.args, append(args, input)...)...)
+		if code != 2 || out != "" || !strings.Contains(stderr, c.stderr) {
+			t.Errorf("%s: expected exit code 2 and %q, got %d: %q %s", c.name, c.stderr, code, out, stderr)
+		}
+		if strings.Count(stderr, "bearer token") > 1 {
+			t.Errorf("%s: rejected request was retried: %s", c.name, stderr)
+		}
+	}
+}
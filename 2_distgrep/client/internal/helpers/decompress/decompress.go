@@ -0,0 +1,75 @@
+package decompress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrNotCompressed is returned when decompression is forced on input in no known format
+var ErrNotCompressed = errors.New("not in gzip, bzip2 or zstd format")
+
+// format is a compressed format recognised by the header its data starts with
+type format struct {
+	match func(head []byte) bool
+	open  func(r io.Reader) (io.ReadCloser, error)
+}
+
+// headerSize is the number of leading bytes needed to recognise every format
+const headerSize = 10
+
+var formats = []format{
+	{match: func(head []byte) bool {
+		// the deflate method, and no reserved flag bits set
+		return len(head) >= 4 && head[0] == 0x1f && head[1] == 0x8b && head[2] == 8 && head[3]&0xe0 == 0
+	}, open: func(r io.Reader) (io.ReadCloser, error) {
+		// concatenated gzip members, as left by appending to a .gz file, are read as one stream
+		return gzip.NewReader(r)
+	}},
+	{match: func(head []byte) bool {
+		// a block size digit, then the magic of the first block, or of the end of an empty stream
+		return len(head) >= 10 && bytes.HasPrefix(head, []byte("BZh")) && head[3] >= '1' && head[3] <= '9' &&
+			(bytes.Equal(head[4:10], []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) ||
+				bytes.Equal(head[4:10], []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}))
+	}, open: func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}},
+	{match: func(head []byte) bool {
+		// the frame magic, and no reserved bit set in the frame header descriptor
+		return len(head) >= 5 && bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}) && head[4]&0x08 == 0
+	}, open: func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}},
+}
+
+// NewReader returns a reader of the decompressed data of r if r starts with a valid
+// gzip, bzip2 or zstd header, like zgrep does, and of r as is otherwise, so that text
+// merely starting with the same bytes as a magic number is searched as text.
+// With force set, data in none of these formats is an error instead.
+// Closing the returned reader does not close r.
+func NewReader(r io.Reader, force bool) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// a shorter input cannot be compressed and is passed on as is
+	head, err := br.Peek(headerSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	for _, f := range formats {
+		if f.match(head) {
+			return f.open(br)
+		}
+	}
+	if force {
+		return nil, ErrNotCompressed
+	}
+	return io.NopCloser(br), nil
+}
@@ -0,0 +1,127 @@
+package walk
+
+import (
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filter holds glob patterns matched against base names of searched files and directories
+type Filter struct {
+	Include    []string // search only files matching one of these, if any are given
+	Exclude    []string // skip files matching one of these
+	ExcludeDir []string // skip directories matching one of these while recursing
+}
+
+// Files yields the files to search for the given command-line operands, in order.
+// "-" stands for stdin. With recursive set, directories are walked in lexical order
+// without following symbolic links, and no operands means the working directory.
+// Errors met while walking are yielded along with the path they concern.
+func Files(operands []string, recursive bool, filter Filter) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if len(operands) == 0 {
+			if !recursive {
+				yield("-", nil)
+				return
+			}
+			// like grep, files under the implicit working directory are named without "./"
+			walkDir(".", filter, func(path string, err error) bool {
+				return yield(strings.TrimPrefix(path, "./"), err)
+			})
+			return
+		}
+
+		for _, op := range operands {
+			if op != "-" && recursive {
+				if info, err := os.Stat(op); err == nil && info.IsDir() {
+					if !walkDir(op, filter, yield) {
+						return
+					}
+					continue
+				}
+			}
+			if op != "-" && !filter.file(filepath.Base(op)) {
+				continue
+			}
+			if !yield(op, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ShowNames reports whether output lines should be prefixed with file names,
+// which grep does whenever more than one file may be searched.
+func ShowNames(operands []string, recursive bool) bool {
+	if len(operands) > 1 {
+		return true
+	}
+	if !recursive {
+		return false
+	}
+	if len(operands) == 0 {
+		return true
+	}
+	info, err := os.Stat(operands[0])
+	return err == nil && info.IsDir()
+}
+
+// walkDir yields the files under root that pass the filter.
+// It returns false if yield asked to stop.
+func walkDir(root string, filter Filter, yield func(string, error) bool) bool {
+	stopped := false
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if !yield(path, err) {
+				stopped = true
+				return filepath.SkipAll
+			}
+			return nil
+		}
+
+		switch {
+		case d.IsDir():
+			if path != root && !filter.dir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		case !d.Type().IsRegular():
+			// symbolic links and special files are skipped, as grep -r does
+			return nil
+		case !filter.file(d.Name()):
+			return nil
+		}
+
+		if !yield(path, nil) {
+			stopped = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return !stopped
+}
+
+// file reports whether a file with the given base name should be searched
+func (f Filter) file(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+// dir reports whether a directory with the given base name should be entered
+func (f Filter) dir(name string) bool {
+	return !matchAny(f.ExcludeDir, name)
+}
+
+// matchAny reports whether name matches any of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,9 @@
 package models
 
+// EncodingBase64 marks tasks and results whose lines are base64-encoded bytes.
+// It is used for data that is not valid UTF-8 and would be mangled in JSON strings.
+const EncodingBase64 = "base64"
+
 // GrepFlags represents the command-line flags for the grep command
 type GrepFlags struct {
 	FixedString  bool `json:"fixed_string"`
@@ -9,29 +13,54 @@ type GrepFlags struct {
 	After        int  `json:"after"`
 	Before       int  `json:"before"`
 	CountOnly    bool `json:"count_only"`
+	WordMatch    bool `json:"word_match"`    // patterns only match whole words
+	LineMatch    bool `json:"line_match"`    // patterns only match whole lines
+	MaxCount     int  `json:"max_count"`     // select at most this many lines per input; 0 means no limit
+	MatchOffsets bool `json:"match_offsets"` // ask servers where patterns match within returned lines
 }
 
 // Task represents a grep task with its pattern, lines, and context
 type Task struct {
 	ID              int       `json:"id"`
-	Pattern         string    `json:"pattern"`
+	Patterns        []string  `json:"patterns"` // a line matches if it matches any of them
 	Lines           []string  `json:"lines"`
 	BeforeContext   []string  `json:"before_context"`
 	AfterContext    []string  `json:"after_context"`
 	StartLineNumber int       `json:"start_line_number"`
 	Flags           GrepFlags `json:"flags"`
+	Encoding        string    `json:"encoding,omitempty"` // encoding of the lines, empty or EncodingBase64
+
+	// Path names a file under the server's root for the server to read the lines from.
+	// The lines searched are those starting within [Offset, Offset+Length), and line
+	// numbers in the result are relative to the first of them, which is line 0.
+	// Servers whose copy of the file is not Size bytes long reject the task.
+	Path   string `json:"path,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Length int64  `json:"length,omitempty"`
+	Size   int64  `json:"size,omitempty"`
 }
 
 // Result represents the result of a grep task
 type Result struct {
 	TaskID      int          `json:"task_id"`
 	FoundBlocks []FoundBlock `json:"found_blocks"`
+	Encoding    string       `json:"encoding,omitempty"`   // encoding of the block lines, empty or EncodingBase64
+	LineCount   int          `json:"line_count,omitempty"` // for tasks with a path, the number of lines in the byte range
+	Binary      bool         `json:"binary,omitempty"`     // for tasks with a path, whether the lines in the byte range hold NUL bytes
+}
+
+// FileInfo describes a file under a server's root
+type FileInfo struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
 }
 
 // FoundBlock represents a found block of lines
 type FoundBlock struct {
-	StartLineNumber int      `json:"start_line_number"`
-	Lines           []string `json:"lines"`
+	StartLineNumber int        `json:"start_line_number"`
+	Lines           []string   `json:"lines"`
+	Matches         []bool     `json:"matches"` // whether each line is selected rather than context
+	Offsets         [][][2]int `json:"offsets"` // byte offsets [start, end) of the matches in each line, if requested
 }
 
 // ParsedAddr represents a parsed address with its scheme, host, and port
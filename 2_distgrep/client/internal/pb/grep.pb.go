@@ -0,0 +1,821 @@
+// Protocol between the distributed grep client and servers over gRPC.
+// It mirrors the JSON API of POST /grep and GET /stat; lines are raw bytes,
+// so no encoding is needed for data that is not valid UTF-8. POST /grep also
+// takes a Task and answers with a Result as application/x-protobuf bodies.
+//
+// The generated code lives in each module; regenerate it with go generate
+// in server/internal/pb and client/internal/pb.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: grep.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Flags struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FixedString   bool                   `protobuf:"varint,1,opt,name=fixed_string,json=fixedString,proto3" json:"fixed_string,omitempty"`
+	PrintNumbers  bool                   `protobuf:"varint,2,opt,name=print_numbers,json=printNumbers,proto3" json:"print_numbers,omitempty"`
+	IgnoreCase    bool                   `protobuf:"varint,3,opt,name=ignore_case,json=ignoreCase,proto3" json:"ignore_case,omitempty"`
+	Invert        bool                   `protobuf:"varint,4,opt,name=invert,proto3" json:"invert,omitempty"`
+	After         int32                  `protobuf:"varint,5,opt,name=after,proto3" json:"after,omitempty"`
+	Before        int32                  `protobuf:"varint,6,opt,name=before,proto3" json:"before,omitempty"`
+	CountOnly     bool                   `protobuf:"varint,7,opt,name=count_only,json=countOnly,proto3" json:"count_only,omitempty"`
+	WordMatch     bool                   `protobuf:"varint,8,opt,name=word_match,json=wordMatch,proto3" json:"word_match,omitempty"`
+	LineMatch     bool                   `protobuf:"varint,9,opt,name=line_match,json=lineMatch,proto3" json:"line_match,omitempty"`
+	MaxCount      int32                  `protobuf:"varint,10,opt,name=max_count,json=maxCount,proto3" json:"max_count,omitempty"`
+	MatchOffsets  bool                   `protobuf:"varint,11,opt,name=match_offsets,json=matchOffsets,proto3" json:"match_offsets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Flags) Reset() {
+	*x = Flags{}
+	mi := &file_grep_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Flags) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Flags) ProtoMessage() {}
+
+func (x *Flags) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Flags.ProtoReflect.Descriptor instead.
+func (*Flags) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Flags) GetFixedString() bool {
+	if x != nil {
+		return x.FixedString
+	}
+	return false
+}
+
+func (x *Flags) GetPrintNumbers() bool {
+	if x != nil {
+		return x.PrintNumbers
+	}
+	return false
+}
+
+func (x *Flags) GetIgnoreCase() bool {
+	if x != nil {
+		return x.IgnoreCase
+	}
+	return false
+}
+
+func (x *Flags) GetInvert() bool {
+	if x != nil {
+		return x.Invert
+	}
+	return false
+}
+
+func (x *Flags) GetAfter() int32 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+func (x *Flags) GetBefore() int32 {
+	if x != nil {
+		return x.Before
+	}
+	return 0
+}
+
+func (x *Flags) GetCountOnly() bool {
+	if x != nil {
+		return x.CountOnly
+	}
+	return false
+}
+
+func (x *Flags) GetWordMatch() bool {
+	if x != nil {
+		return x.WordMatch
+	}
+	return false
+}
+
+func (x *Flags) GetLineMatch() bool {
+	if x != nil {
+		return x.LineMatch
+	}
+	return false
+}
+
+func (x *Flags) GetMaxCount() int32 {
+	if x != nil {
+		return x.MaxCount
+	}
+	return 0
+}
+
+func (x *Flags) GetMatchOffsets() bool {
+	if x != nil {
+		return x.MatchOffsets
+	}
+	return false
+}
+
+type Task struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Patterns        []string               `protobuf:"bytes,2,rep,name=patterns,proto3" json:"patterns,omitempty"`
+	Lines           [][]byte               `protobuf:"bytes,3,rep,name=lines,proto3" json:"lines,omitempty"`
+	BeforeContext   [][]byte               `protobuf:"bytes,4,rep,name=before_context,json=beforeContext,proto3" json:"before_context,omitempty"`
+	AfterContext    [][]byte               `protobuf:"bytes,5,rep,name=after_context,json=afterContext,proto3" json:"after_context,omitempty"`
+	StartLineNumber int64                  `protobuf:"varint,6,opt,name=start_line_number,json=startLineNumber,proto3" json:"start_line_number,omitempty"`
+	Flags           *Flags                 `protobuf:"bytes,7,opt,name=flags,proto3" json:"flags,omitempty"`
+	// With path set, lines are read by the server from the byte range
+	// [offset, offset+length) of the file under its root.
+	Path   string `protobuf:"bytes,8,opt,name=path,proto3" json:"path,omitempty"`
+	Offset int64  `protobuf:"varint,9,opt,name=offset,proto3" json:"offset,omitempty"`
+	Length int64  `protobuf:"varint,10,opt,name=length,proto3" json:"length,omitempty"`
+	// With size set, servers whose copy of the file has another size reject the task.
+	Size          int64 `protobuf:"varint,11,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Task) Reset() {
+	*x = Task{}
+	mi := &file_grep_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Task) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Task) ProtoMessage() {}
+
+func (x *Task) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Task.ProtoReflect.Descriptor instead.
+func (*Task) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Task) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Task) GetPatterns() []string {
+	if x != nil {
+		return x.Patterns
+	}
+	return nil
+}
+
+func (x *Task) GetLines() [][]byte {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *Task) GetBeforeContext() [][]byte {
+	if x != nil {
+		return x.BeforeContext
+	}
+	return nil
+}
+
+func (x *Task) GetAfterContext() [][]byte {
+	if x != nil {
+		return x.AfterContext
+	}
+	return nil
+}
+
+func (x *Task) GetStartLineNumber() int64 {
+	if x != nil {
+		return x.StartLineNumber
+	}
+	return 0
+}
+
+func (x *Task) GetFlags() *Flags {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *Task) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Task) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Task) GetLength() int64 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *Task) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type FoundBlock struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	StartLineNumber int64                  `protobuf:"varint,1,opt,name=start_line_number,json=startLineNumber,proto3" json:"start_line_number,omitempty"`
+	Lines           [][]byte               `protobuf:"bytes,2,rep,name=lines,proto3" json:"lines,omitempty"`
+	Matches         []bool                 `protobuf:"varint,3,rep,packed,name=matches,proto3" json:"matches,omitempty"`
+	// one entry per line when offsets were requested
+	Offsets       []*Offsets `protobuf:"bytes,4,rep,name=offsets,proto3" json:"offsets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FoundBlock) Reset() {
+	*x = FoundBlock{}
+	mi := &file_grep_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FoundBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FoundBlock) ProtoMessage() {}
+
+func (x *FoundBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FoundBlock.ProtoReflect.Descriptor instead.
+func (*FoundBlock) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FoundBlock) GetStartLineNumber() int64 {
+	if x != nil {
+		return x.StartLineNumber
+	}
+	return 0
+}
+
+func (x *FoundBlock) GetLines() [][]byte {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *FoundBlock) GetMatches() []bool {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+func (x *FoundBlock) GetOffsets() []*Offsets {
+	if x != nil {
+		return x.Offsets
+	}
+	return nil
+}
+
+// Offsets holds the byte offsets of the matches in a line as start, end pairs.
+type Offsets struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        []int32                `protobuf:"varint,1,rep,packed,name=bounds,proto3" json:"bounds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Offsets) Reset() {
+	*x = Offsets{}
+	mi := &file_grep_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Offsets) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Offsets) ProtoMessage() {}
+
+func (x *Offsets) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Offsets.ProtoReflect.Descriptor instead.
+func (*Offsets) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Offsets) GetBounds() []int32 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TaskId        int64                  `protobuf:"varint,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	LineCount     int64                  `protobuf:"varint,2,opt,name=line_count,json=lineCount,proto3" json:"line_count,omitempty"`
+	Binary        bool                   `protobuf:"varint,3,opt,name=binary,proto3" json:"binary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_grep_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Summary) GetTaskId() int64 {
+	if x != nil {
+		return x.TaskId
+	}
+	return 0
+}
+
+func (x *Summary) GetLineCount() int64 {
+	if x != nil {
+		return x.LineCount
+	}
+	return 0
+}
+
+func (x *Summary) GetBinary() bool {
+	if x != nil {
+		return x.Binary
+	}
+	return false
+}
+
+type SearchReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Reply:
+	//
+	//	*SearchReply_Block
+	//	*SearchReply_Summary
+	Reply         isSearchReply_Reply `protobuf_oneof:"reply"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchReply) Reset() {
+	*x = SearchReply{}
+	mi := &file_grep_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchReply) ProtoMessage() {}
+
+func (x *SearchReply) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchReply.ProtoReflect.Descriptor instead.
+func (*SearchReply) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SearchReply) GetReply() isSearchReply_Reply {
+	if x != nil {
+		return x.Reply
+	}
+	return nil
+}
+
+func (x *SearchReply) GetBlock() *FoundBlock {
+	if x != nil {
+		if x, ok := x.Reply.(*SearchReply_Block); ok {
+			return x.Block
+		}
+	}
+	return nil
+}
+
+func (x *SearchReply) GetSummary() *Summary {
+	if x != nil {
+		if x, ok := x.Reply.(*SearchReply_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isSearchReply_Reply interface {
+	isSearchReply_Reply()
+}
+
+type SearchReply_Block struct {
+	Block *FoundBlock `protobuf:"bytes,1,opt,name=block,proto3,oneof"`
+}
+
+type SearchReply_Summary struct {
+	Summary *Summary `protobuf:"bytes,2,opt,name=summary,proto3,oneof"`
+}
+
+func (*SearchReply_Block) isSearchReply_Reply() {}
+
+func (*SearchReply_Summary) isSearchReply_Reply() {}
+
+// Result is the answer of POST /grep to a Task: the blocks Search would stream, then its summary.
+type Result struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Blocks        []*FoundBlock          `protobuf:"bytes,1,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	Summary       *Summary               `protobuf:"bytes,2,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_grep_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Result) GetBlocks() []*FoundBlock {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *Result) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type StatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_grep_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRequest) ProtoMessage() {}
+
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StatRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type FileInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_grep_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_grep_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_grep_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FileInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+var File_grep_proto protoreflect.FileDescriptor
+
+const file_grep_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"grep.proto\x12\vdistgrep.v1\"\xd5\x02\n" +
+	"\x05Flags\x12!\n" +
+	"\ffixed_string\x18\x01 \x01(\bR\vfixedString\x12#\n" +
+	"\rprint_numbers\x18\x02 \x01(\bR\fprintNumbers\x12\x1f\n" +
+	"\vignore_case\x18\x03 \x01(\bR\n" +
+	"ignoreCase\x12\x16\n" +
+	"\x06invert\x18\x04 \x01(\bR\x06invert\x12\x14\n" +
+	"\x05after\x18\x05 \x01(\x05R\x05after\x12\x16\n" +
+	"\x06before\x18\x06 \x01(\x05R\x06before\x12\x1d\n" +
+	"\n" +
+	"count_only\x18\a \x01(\bR\tcountOnly\x12\x1d\n" +
+	"\n" +
+	"word_match\x18\b \x01(\bR\twordMatch\x12\x1d\n" +
+	"\n" +
+	"line_match\x18\t \x01(\bR\tlineMatch\x12\x1b\n" +
+	"\tmax_count\x18\n" +
+	" \x01(\x05R\bmaxCount\x12#\n" +
+	"\rmatch_offsets\x18\v \x01(\bR\fmatchOffsets\"\xc2\x02\n" +
+	"\x04Task\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\bpatterns\x18\x02 \x03(\tR\bpatterns\x12\x14\n" +
+	"\x05lines\x18\x03 \x03(\fR\x05lines\x12%\n" +
+	"\x0ebefore_context\x18\x04 \x03(\fR\rbeforeContext\x12#\n" +
+	"\rafter_context\x18\x05 \x03(\fR\fafterContext\x12*\n" +
+	"\x11start_line_number\x18\x06 \x01(\x03R\x0fstartLineNumber\x12(\n" +
+	"\x05flags\x18\a \x01(\v2\x12.distgrep.v1.FlagsR\x05flags\x12\x12\n" +
+	"\x04path\x18\b \x01(\tR\x04path\x12\x16\n" +
+	"\x06offset\x18\t \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06length\x18\n" +
+	" \x01(\x03R\x06length\x12\x12\n" +
+	"\x04size\x18\v \x01(\x03R\x04size\"\x98\x01\n" +
+	"\n" +
+	"FoundBlock\x12*\n" +
+	"\x11start_line_number\x18\x01 \x01(\x03R\x0fstartLineNumber\x12\x14\n" +
+	"\x05lines\x18\x02 \x03(\fR\x05lines\x12\x18\n" +
+	"\amatches\x18\x03 \x03(\bR\amatches\x12.\n" +
+	"\aoffsets\x18\x04 \x03(\v2\x14.distgrep.v1.OffsetsR\aoffsets\"!\n" +
+	"\aOffsets\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\x05R\x06bounds\"Y\n" +
+	"\aSummary\x12\x17\n" +
+	"\atask_id\x18\x01 \x01(\x03R\x06taskId\x12\x1d\n" +
+	"\n" +
+	"line_count\x18\x02 \x01(\x03R\tlineCount\x12\x16\n" +
+	"\x06binary\x18\x03 \x01(\bR\x06binary\"y\n" +
+	"\vSearchReply\x12/\n" +
+	"\x05block\x18\x01 \x01(\v2\x17.distgrep.v1.FoundBlockH\x00R\x05block\x120\n" +
+	"\asummary\x18\x02 \x01(\v2\x14.distgrep.v1.SummaryH\x00R\asummaryB\a\n" +
+	"\x05reply\"i\n" +
+	"\x06Result\x12/\n" +
+	"\x06blocks\x18\x01 \x03(\v2\x17.distgrep.v1.FoundBlockR\x06blocks\x12.\n" +
+	"\asummary\x18\x02 \x01(\v2\x14.distgrep.v1.SummaryR\asummary\"!\n" +
+	"\vStatRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"2\n" +
+	"\bFileInfo\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size2x\n" +
+	"\x04Grep\x127\n" +
+	"\x06Search\x12\x11.distgrep.v1.Task\x1a\x18.distgrep.v1.SearchReply0\x01\x127\n" +
+	"\x04Stat\x12\x18.distgrep.v1.StatRequest\x1a\x15.distgrep.v1.FileInfob\x06proto3"
+
+var (
+	file_grep_proto_rawDescOnce sync.Once
+	file_grep_proto_rawDescData []byte
+)
+
+func file_grep_proto_rawDescGZIP() []byte {
+	file_grep_proto_rawDescOnce.Do(func() {
+		file_grep_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grep_proto_rawDesc), len(file_grep_proto_rawDesc)))
+	})
+	return file_grep_proto_rawDescData
+}
+
+var file_grep_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_grep_proto_goTypes = []any{
+	(*Flags)(nil),       // 0: distgrep.v1.Flags
+	(*Task)(nil),        // 1: distgrep.v1.Task
+	(*FoundBlock)(nil),  // 2: distgrep.v1.FoundBlock
+	(*Offsets)(nil),     // 3: distgrep.v1.Offsets
+	(*Summary)(nil),     // 4: distgrep.v1.Summary
+	(*SearchReply)(nil), // 5: distgrep.v1.SearchReply
+	(*Result)(nil),      // 6: distgrep.v1.Result
+	(*StatRequest)(nil), // 7: distgrep.v1.StatRequest
+	(*FileInfo)(nil),    // 8: distgrep.v1.FileInfo
+}
+var file_grep_proto_depIdxs = []int32{
+	0, // 0: distgrep.v1.Task.flags:type_name -> distgrep.v1.Flags
+	3, // 1: distgrep.v1.FoundBlock.offsets:type_name -> distgrep.v1.Offsets
+	2, // 2: distgrep.v1.SearchReply.block:type_name -> distgrep.v1.FoundBlock
+	4, // 3: distgrep.v1.SearchReply.summary:type_name -> distgrep.v1.Summary
+	2, // 4: distgrep.v1.Result.blocks:type_name -> distgrep.v1.FoundBlock
+	4, // 5: distgrep.v1.Result.summary:type_name -> distgrep.v1.Summary
+	1, // 6: distgrep.v1.Grep.Search:input_type -> distgrep.v1.Task
+	7, // 7: distgrep.v1.Grep.Stat:input_type -> distgrep.v1.StatRequest
+	5, // 8: distgrep.v1.Grep.Search:output_type -> distgrep.v1.SearchReply
+	8, // 9: distgrep.v1.Grep.Stat:output_type -> distgrep.v1.FileInfo
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_grep_proto_init() }
+func file_grep_proto_init() {
+	if File_grep_proto != nil {
+		return
+	}
+	file_grep_proto_msgTypes[5].OneofWrappers = []any{
+		(*SearchReply_Block)(nil),
+		(*SearchReply_Summary)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grep_proto_rawDesc), len(file_grep_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grep_proto_goTypes,
+		DependencyIndexes: file_grep_proto_depIdxs,
+		MessageInfos:      file_grep_proto_msgTypes,
+	}.Build()
+	File_grep_proto = out.File
+	file_grep_proto_goTypes = nil
+	file_grep_proto_depIdxs = nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"client/internal/models"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// binaryPeek is how much of an input is checked for binary data before its
+// first line is read, like the first buffer grep reads
+const binaryPeek = 32 << 10
+
+// chunker splits an input stream into tasks of a fixed number of lines.
+// Each task carries the lines preceding and following it that are needed
+// for context, so memory stays bounded by the chunk size plus context.
+// Lines may be of any length and contain any bytes.
+type chunker struct {
+	reader   *bufio.Reader
+	patterns []string
+	flags    models.GrepFlags
+	size     int
+
+	tail    []string // last flags.Before lines before pending
+	pending []string // lines read but not yet emitted
+	next    int      // line number of pending[0]
+	started bool
+	eof     bool
+	binary  bool // binary data was found in the input so far
+}
+
+// newChunker creates a chunker reading from r.
+func newChunker(r io.Reader, patterns []string, flags models.GrepFlags, size int) *chunker {
+	return &chunker{
+		reader:   bufio.NewReaderSize(r, binaryPeek),
+		patterns: patterns,
+		flags:    flags,
+		size:     max(size, 1),
+		next:     1,
+	}
+}
+
+// Next returns the next task. It returns false once the input is exhausted.
+func (c *chunker) Next() (models.Task, bool, error) {
+	if !c.started {
+		c.started = true
+		head, err := c.reader.Peek(binaryPeek)
+		if err != nil && err != io.EOF {
+			return models.Task{}, false, err
+		}
+		data := head
+		if err == nil {
+			// the input goes on, so its last line, or even character, may be cut short
+			data = head[:bytes.LastIndexByte(head, '\n')+1]
+		}
+		c.binary = bytes.IndexByte(head, 0) >= 0 || utf8Locale() && !utf8.Valid(data)
+	}
+
+	// read ahead far enough to provide the after-context of this chunk
+	for !c.eof && len(c.pending) < c.size+c.flags.After {
+		line, err := c.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return models.Task{}, false, err
+		}
+		if err == io.EOF {
+			c.eof = true
+			// a final line without newline is still a line
+			if line == "" {
+				break
+			}
+		}
+		c.pending = append(c.pending, strings.TrimSuffix(line, "\n"))
+	}
+	if len(c.pending) == 0 {
+		return models.Task{}, false, nil
+	}
+
+	n := min(c.size, len(c.pending))
+	end := min(n+c.flags.After, len(c.pending))
+	task := models.Task{
+		Patterns:        c.patterns,
+		Lines:           c.pending[:n:n],
+		BeforeContext:   c.tail,
+		AfterContext:    c.pending[n:end:end],
+		StartLineNumber: c.next,
+		Flags:           c.flags,
+	}
+	for _, line := range task.Lines {
+		c.binary = c.binary || binaryLine(line)
+	}
+
+	// read lines are never written to again, so tasks and tail may keep referencing them
+	if keep := c.flags.Before - n; keep > 0 {
+		c.tail = append(append([]string{}, c.tail[max(0, len(c.tail)-keep):]...), task.Lines...)
+	} else {
+		c.tail = task.Lines[n-c.flags.Before:]
+	}
+	c.pending = c.pending[n:]
+	c.next += n
+
+	return task, true, nil
+}
+
+// Binary reports whether binary data was found in the input up to the end of the last task.
+func (c *chunker) Binary() bool {
+	return c.binary
+}
+
+// binaryLine reports whether s is binary data: like grep, data containing NUL bytes,
+// or in a UTF-8 locale data that is not valid UTF-8
+func binaryLine(s string) bool {
+	return strings.IndexByte(s, 0) >= 0 || utf8Locale() && !utf8.ValidString(s)
+}
+
+// utf8Locale reports whether the locale set in the environment uses UTF-8
+var utf8Locale = sync.OnceValue(func() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := strings.ToLower(os.Getenv(name)); v != "" {
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+})
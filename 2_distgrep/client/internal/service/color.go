@@ -0,0 +1,73 @@
+package service
+
+import "strings"
+
+// palette holds the SGR color codes used to highlight output, read from
+// GREP_COLORS like grep does; see grep(1) for the meaning of the keys.
+// The zero palette has no colors.
+type palette struct {
+	selectedMatch string // ms: matched text in selected lines
+	contextMatch  string // mc: matched text in context lines
+	fileName      string // fn
+	lineNumber    string // ln
+	separator     string // se: separators between fields and groups
+	erase         string // erases to the end of line after each sequence, unless ne is set
+}
+
+// newPalette parses a GREP_COLORS value on top of grep's defaults.
+// Unknown or unsupported keys are ignored.
+func newPalette(spec string) palette {
+	p := palette{
+		selectedMatch: "01;31",
+		contextMatch:  "01;31",
+		fileName:      "35",
+		lineNumber:    "32",
+		separator:     "36",
+		erase:         "\x1b[K",
+	}
+	for _, field := range strings.Split(spec, ":") {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "mt":
+			p.selectedMatch, p.contextMatch = value, value
+		case "ms":
+			p.selectedMatch = value
+		case "mc":
+			p.contextMatch = value
+		case "fn":
+			p.fileName = value
+		case "ln":
+			p.lineNumber = value
+		case "se":
+			p.separator = value
+		case "ne":
+			p.erase = ""
+		}
+	}
+	return p
+}
+
+// paint wraps s in the color code; an empty code leaves s as is
+func (p palette) paint(code, s string) string {
+	if code == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + p.erase + s + "\x1b[m" + p.erase
+}
+
+// highlight paints the parts of s at offsets with the color code;
+// offsets must be in order and within s
+func (p palette) highlight(code, s string, offsets [][2]int) string {
+	if code == "" || len(offsets) == 0 {
+		return s
+	}
+	var b strings.Builder
+	prev := 0
+	for _, o := range offsets {
+		b.WriteString(s[prev:o[0]])
+		b.WriteString(p.paint(code, s[o[0]:o[1]]))
+		prev = o[1]
+	}
+	b.WriteString(s[prev:])
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings for task and result bodies on the HTTP transport
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+var (
+	// EncodeAll and DecodeAll may be used concurrently, so a single coder serves all tasks
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+	gzipWriters    = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+)
+
+// compressBody compresses a request body with the coding
+func compressBody(data []byte, coding string) ([]byte, error) {
+	switch coding {
+	case CompressGzip:
+		var buf bytes.Buffer
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+	return data, nil
+}
+
+// decodedBody returns a reader of the body of resp decompressed according to its Content-Encoding.
+// Closing it does not close the body.
+func decodedBody(resp *http.Response) (io.ReadCloser, error) {
+	switch coding := resp.Header.Get("Content-Encoding"); coding {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case CompressGzip:
+		return gzip.NewReader(resp.Body)
+	case CompressZstd:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		data, err = zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", coding)
+	}
+}
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"bytes"
+	"client/internal/models"
+	"client/internal/pb"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// errPermanent marks failures that would repeat on any server, such as an invalid pattern
+var errPermanent = errors.New("request rejected")
+
+// pool hands out servers for tasks and steers retries away from servers that recently failed
+type pool struct {
+	servers   []*models.ParsedAddr
+	transport *transport
+
+	mu     sync.Mutex
+	failed map[*models.ParsedAddr]bool
+}
+
+// newPool creates a pool over the given servers, reached through t
+func newPool(servers []*models.ParsedAddr, t *transport) *pool {
+	return &pool{servers: servers, transport: t, failed: make(map[*models.ParsedAddr]bool, len(servers))}
+}
+
+// placement tracks which server holds which replica of a task, so that
+// every replica is answered by a different server
+type placement map[*models.ParsedAddr]int
+
+// pick returns a server for the given replica, searching round-robin from start.
+// It skips servers holding another replica of the same task and prefers servers
+// the replica has not tried yet and that have not failed recently.
+// It returns nil if every server holds another replica.
+func (p *pool) pick(start, replica int, owners placement, tried map[*models.ParsedAddr]bool) *models.ParsedAddr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *models.ParsedAddr
+	bestRank := 4
+	n := len(p.servers)
+	for i := range n {
+		addr := p.servers[(start+i)%n]
+		if owner, ok := owners[addr]; ok && owner != replica {
+			continue
+		}
+		rank := 0
+		if tried[addr] {
+			rank += 2
+		}
+		if p.failed[addr] {
+			rank++
+		}
+		if rank < bestRank {
+			best, bestRank = addr, rank
+		}
+	}
+	if best != nil {
+		owners[best] = replica
+	}
+	return best
+}
+
+// report records whether a request to addr succeeded. A failed server
+// is released so that another replica of the task may retry on it.
+func (p *pool) report(addr *models.ParsedAddr, owners placement, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failed[addr] = err != nil
+	if err != nil {
+		delete(owners, addr)
+	}
+}
+
+// replicate sends task seq to opts.Replicas distinct servers and accepts the result
+// returned identically by at least opts.Quorum of them. Replicas that disagree
+// with the accepted result are reported in its disagreement field.
+// Cancelling ctx abandons the task.
+func (p *pool) replicate(ctx context.Context, seq int, task models.Task, opts Options, errOut io.Writer) chunkResult {
+	owners := make(placement, opts.Replicas)
+	results := make([]models.Result, opts.Replicas)
+	errs := make([]error, opts.Replicas)
+
+	var wg sync.WaitGroup
+	for r := range opts.Replicas {
+		wg.Go(func() {
+			results[r], errs[r] = p.send(ctx, seq+r, r, task, owners, opts, errOut)
+		})
+	}
+	wg.Wait()
+
+	lines := fmt.Sprintf("lines %d-%d", task.StartLineNumber, task.StartLineNumber+len(task.Lines)-1)
+	if task.Path != "" {
+		lines = fmt.Sprintf("bytes %d-%d", task.Offset, task.Offset+task.Length-1)
+	}
+	ok := make([]models.Result, 0, len(results))
+	var lastErr error
+	for r := range results {
+		if errs[r] != nil {
+			lastErr = errs[r]
+			continue
+		}
+		ok = append(ok, results[r])
+	}
+	if len(ok) == 0 {
+		return chunkResult{err: lastErr}
+	}
+
+	accepted, agree := vote(ok)
+	if agree < opts.Quorum {
+		return chunkResult{err: fmt.Errorf("%s: no quorum, %d of %d replicas agree, need %d", lines, agree, opts.Replicas, opts.Quorum)}
+	}
+	var disagreement error
+	if agree < len(ok) {
+		disagreement = fmt.Errorf("%s: %d of %d answering replicas disagree with the accepted result", lines, len(ok)-agree, len(ok))
+		if failed := opts.Replicas - len(ok); failed > 0 {
+			disagreement = fmt.Errorf("%w, %d failed", disagreement, failed)
+		}
+	}
+	return chunkResult{result: accepted, disagreement: disagreement}
+}
+
+// send sends one replica of a task, retrying failed attempts on other servers
+// with exponential backoff. Each failed attempt is logged to errOut.
+func (p *pool) send(ctx context.Context, start, replica int, task models.Task, owners placement, opts Options, errOut io.Writer) (models.Result, error) {
+	tried := make(map[*models.ParsedAddr]bool, opts.Retries+1)
+	var result models.Result
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(opts.RetryBackoff << (attempt - 1)):
+			}
+		}
+
+		addr := p.pick(start, replica, owners, tried)
+		if addr == nil {
+			return result, fmt.Errorf("no server left for replica %d: %w", replica+1, err)
+		}
+		tried[addr] = true
+
+		attemptCtx, cancel := p.transport.withTimeout(ctx)
+		result, err = p.transport.sendTask(attemptCtx, addr, task)
+		err = p.transport.timeoutError(ctx, attemptCtx, addr, err)
+		cancel()
+		if errors.Is(err, errPermanent) || ctx.Err() != nil {
+			return result, err
+		}
+		p.report(addr, owners, err)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Fprintln(errOut, err)
+	}
+	return result, err
+}
+
+// vote returns the most common of the results and the number of results equal to it
+func vote(results []models.Result) (models.Result, int) {
+	var best models.Result
+	bestCount := 0
+	for i := range results {
+		count := 0
+		for j := range results {
+			if sameBlocks(results[i].FoundBlocks, results[j].FoundBlocks) &&
+				results[i].LineCount == results[j].LineCount && results[i].Binary == results[j].Binary {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = results[i], count
+		}
+	}
+	return best, bestCount
+}
+
+// sameBlocks reports whether two results found the same blocks
+func sameBlocks(a, b []models.FoundBlock) bool {
+	return slices.EqualFunc(a, b, func(x, y models.FoundBlock) bool {
+		return x.StartLineNumber == y.StartLineNumber && slices.Equal(x.Lines, y.Lines) && slices.Equal(x.Matches, y.Matches) &&
+			slices.EqualFunc(x.Offsets, y.Offsets, slices.Equal)
+	})
+}
+
+// checkHealth reports whether the server at addr is ready, as an error if it is not
+func (t *transport) checkHealth(addr *models.ParsedAddr) error {
+	if isGRPC(addr) {
+		return t.checkHealthGRPC(addr)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL(addr)+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server %s:%s returned status %d", addr.Host, addr.Port, resp.StatusCode)
+	}
+	return nil
+}
+
+// sendTask sends a task to the server at addr and returns its result.
+// Over HTTP, the task and result bodies are framed with the framing of t,
+// and compressed with its coding unless it is CompressNone.
+func (t *transport) sendTask(ctx context.Context, addr *models.ParsedAddr, task models.Task) (models.Result, error) {
+	if isGRPC(addr) {
+		return t.sendTaskGRPC(ctx, addr, task)
+	}
+	var result models.Result
+	hostPort := addr.Host + ":" + addr.Port
+
+	data, contentType, err := t.marshalTask(task)
+	if err != nil {
+		return result, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := t.postTask(ctx, addr, data, contentType, t.compress)
+	if err == nil && resp.StatusCode == http.StatusUnsupportedMediaType && t.compress != CompressNone {
+		// the server does not support the coding, so the task is sent as is
+		resp.Body.Close()
+		resp, err = t.postTask(ctx, addr, data, contentType, CompressNone)
+	}
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	body, err := decodedBody(resp)
+	if err != nil {
+		return result, fmt.Errorf("failed to decode response from %s: %w", hostPort, err)
+	}
+	defer body.Close()
+	resp.Body = body
+
+	if err := statusError(resp, hostPort); err != nil {
+		return result, err
+	}
+
+	if result, err = t.unmarshalResult(resp.Body); err != nil {
+		return result, fmt.Errorf("failed to decode response from %s: %w", hostPort, err)
+	}
+	return result, nil
+}
+
+// marshalTask returns the body of a request carrying task in the framing of t, and its Content-Type
+func (t *transport) marshalTask(task models.Task) ([]byte, string, error) {
+	if t.framing == FramingProtobuf {
+		// lines are bytes in protobuf, so they need no encoding
+		data, err := proto.Marshal(taskToPB(task))
+		return data, mimeProtobuf, err
+	}
+	data, err := json.Marshal(encodeTask(task))
+	return data, "application/json", err
+}
+
+// unmarshalResult reads a result in the framing of t from a response body
+func (t *transport) unmarshalResult(body io.Reader) (models.Result, error) {
+	var result models.Result
+	if t.framing != FramingProtobuf {
+		if err := json.NewDecoder(body).Decode(&result); err != nil {
+			return result, err
+		}
+		return result, decodeResult(&result)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return result, err
+	}
+	var r pb.Result
+	if err := proto.Unmarshal(data, &r); err != nil {
+		return result, err
+	}
+	for _, b := range r.GetBlocks() {
+		result.FoundBlocks = append(result.FoundBlocks, blockFromPB(b))
+	}
+	result.TaskID = int(r.GetSummary().GetTaskId())
+	result.LineCount = int(r.GetSummary().GetLineCount())
+	result.Binary = r.GetSummary().GetBinary()
+	return result, nil
+}
+
+// postTask posts the body of a task to the server at addr, compressed with the coding.
+// The server is asked to compress its response the same way.
+func (t *transport) postTask(ctx context.Context, addr *models.ParsedAddr, data []byte, contentType, compress string) (*http.Response, error) {
+	body, err := compressBody(data, compress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL(addr)+"/grep", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if compress == CompressNone {
+		// otherwise the transport asks for gzip on its own
+		req.Header.Set("Accept-Encoding", "identity")
+	} else {
+		req.Header.Set("Content-Encoding", compress)
+		req.Header.Set("Accept-Encoding", compress)
+	}
+	t.authorize(req)
+
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s:%s: %w", addr.Host, addr.Port, err)
+	}
+	return resp, nil
+}
+
+// statusError returns the error reported by a response that is not successful
+func statusError(resp *http.Response, hostPort string) error {
+	// client errors are caused by the request itself, so another server would reject it too
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("%w by %s: %s", errPermanent, hostPort, body.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server %s returned status %d", hostPort, resp.StatusCode)
+	}
+	return nil
+}
+
+// stat asks every server for the size of the file at path under its root.
+// Servers must hold identical copies of the file, so answers of different sizes
+// are an error; shards are searched through a pool of the one server holding each. Servers that cannot be reached are skipped;
+// the size sent with every byte range lets them reject a different copy later.
+func (p *pool) stat(ctx context.Context, path string) (models.FileInfo, error) {
+	var info models.FileInfo
+	var from *models.ParsedAddr
+	var lastErr error
+	for _, addr := range p.servers {
+		attemptCtx, cancel := p.transport.withTimeout(ctx)
+		got, err := p.transport.statFile(attemptCtx, addr, path)
+		err = p.transport.timeoutError(ctx, attemptCtx, addr, err)
+		cancel()
+		if errors.Is(err, errPermanent) || ctx.Err() != nil {
+			return info, err
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if from != nil && got.Size != info.Size {
+			return info, fmt.Errorf("servers hold different copies: %s:%s has %d bytes, %s:%s has %d (use --shards if each holds a shard)",
+				from.Host, from.Port, info.Size, addr.Host, addr.Port, got.Size)
+		}
+		info, from = got, addr
+	}
+	if from == nil {
+		return info, lastErr
+	}
+	return info, nil
+}
+
+// statFile asks the server at addr for the size of the file at path under its root
+func (t *transport) statFile(ctx context.Context, addr *models.ParsedAddr, path string) (models.FileInfo, error) {
+	if isGRPC(addr) {
+		return t.statFileGRPC(ctx, addr, path)
+	}
+	var info models.FileInfo
+	hostPort := addr.Host + ":" + addr.Port
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL(addr)+"/stat?path="+url.QueryEscape(path), nil)
+	if err != nil {
+		return info, fmt.Errorf("failed to create request: %w", err)
+	}
+	t.authorize(req)
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return info, fmt.Errorf("failed to send request to %s: %w", hostPort, err)
+	}
+	defer resp.Body.Close()
+
+	if err := statusError(resp, hostPort); err != nil {
+		return info, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return info, fmt.Errorf("failed to decode response from %s: %w", hostPort, err)
+	}
+	return info, nil
+}
+
+// encodeTask returns task with its lines base64-encoded if any of them is not valid UTF-8.
+// The lines of task are shared with other tasks, so they are copied rather than modified.
+func encodeTask(task models.Task) models.Task {
+	lists := []*[]string{&task.Lines, &task.BeforeContext, &task.AfterContext}
+	valid := true
+	for _, lines := range lists {
+		for _, s := range *lines {
+			valid = valid && utf8.ValidString(s)
+		}
+	}
+	if valid {
+		return task
+	}
+
+	for _, lines := range lists {
+		encoded := make([]string, len(*lines))
+		for i, s := range *lines {
+			encoded[i] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		*lines = encoded
+	}
+	task.Encoding = models.EncodingBase64
+	return task
+}
+
+// decodeResult replaces base64-encoded block lines of result by the bytes they encode
+func decodeResult(result *models.Result) error {
+	switch result.Encoding {
+	case "":
+		return nil
+	case models.EncodingBase64:
+	default:
+		return fmt.Errorf("unknown encoding %q", result.Encoding)
+	}
+
+	for _, b := range result.FoundBlocks {
+		for i, s := range b.Lines {
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return err
+			}
+			b.Lines[i] = string(data)
+		}
+	}
+	result.Encoding = ""
+	return nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"client/internal/models"
+	"client/internal/pb"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// grpcConn returns the connection to the gRPC server at addr, creating it on first use.
+// The connection is shared by all tasks sent to the server.
+func (t *transport) grpcConn(addr *models.ParsedAddr) (*grpc.ClientConn, error) {
+	hostPort := addr.Host + ":" + addr.Port
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conn, ok := t.conns[hostPort]; ok {
+		return conn, nil
+	}
+	creds := insecure.NewCredentials()
+	if addr.Scheme == schemeGRPCS {
+		creds = credentials.NewTLS(t.tls)
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		// blocks are as large as the lines they hold
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32), grpc.MaxCallSendMsgSize(math.MaxInt32)),
+	}
+	if t.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerToken{token: t.token, secure: !t.plain}))
+	}
+	conn, err := grpc.NewClient(hostPort, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", hostPort, err)
+	}
+	t.conns[hostPort] = conn
+	return conn, nil
+}
+
+// bearerToken sends the token in the authorization metadata of every call
+type bearerToken struct {
+	token  string
+	secure bool
+}
+
+func (b bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + b.token}, nil
+}
+
+// RequireTransportSecurity keeps the token off plain gRPC connections unless --insecure-token allows it
+func (b bearerToken) RequireTransportSecurity() bool {
+	return b.secure
+}
+
+// checkHealthGRPC asks the gRPC server at addr for its health
+func (t *transport) checkHealthGRPC(addr *models.ParsedAddr) error {
+	conn, err := t.grpcConn(addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("server %s:%s is %s", addr.Host, addr.Port, resp.GetStatus())
+	}
+	return nil
+}
+
+// sendTaskGRPC sends a task to the gRPC server at addr and collects the blocks it streams back
+func (t *transport) sendTaskGRPC(ctx context.Context, addr *models.ParsedAddr, task models.Task) (models.Result, error) {
+	var result models.Result
+	hostPort := addr.Host + ":" + addr.Port
+	conn, err := t.grpcConn(addr)
+	if err != nil {
+		return result, err
+	}
+
+	stream, err := pb.NewGrepClient(conn).Search(ctx, taskToPB(task))
+	if err != nil {
+		return result, grpcError(hostPort, err)
+	}
+	for {
+		reply, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return result, fmt.Errorf("stream from %s ended without a summary", hostPort)
+		}
+		if err != nil {
+			return result, grpcError(hostPort, err)
+		}
+		switch r := reply.GetReply().(type) {
+		case *pb.SearchReply_Block:
+			result.FoundBlocks = append(result.FoundBlocks, blockFromPB(r.Block))
+		case *pb.SearchReply_Summary:
+			result.TaskID = int(r.Summary.GetTaskId())
+			result.LineCount = int(r.Summary.GetLineCount())
+			result.Binary = r.Summary.GetBinary()
+			return result, nil
+		}
+	}
+}
+
+// statFileGRPC asks the gRPC server at addr for the size of the file at path under its root
+func (t *transport) statFileGRPC(ctx context.Context, addr *models.ParsedAddr, path string) (models.FileInfo, error) {
+	conn, err := t.grpcConn(addr)
+	if err != nil {
+		return models.FileInfo{}, err
+	}
+	info, err := pb.NewGrepClient(conn).Stat(ctx, &pb.StatRequest{Path: path})
+	if err != nil {
+		return models.FileInfo{}, grpcError(addr.Host+":"+addr.Port, err)
+	}
+	return models.FileInfo{Path: info.GetPath(), Size: info.GetSize()}, nil
+}
+
+// grpcError marks errors caused by the request itself as permanent, like statusError does
+func grpcError(hostPort string, err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition, codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Errorf("%w by %s: %s", errPermanent, hostPort, status.Convert(err).Message())
+	}
+	return fmt.Errorf("request to %s failed: %w", hostPort, err)
+}
+
+// taskToPB converts a task for sending over gRPC
+func taskToPB(task models.Task) *pb.Task {
+	f := task.Flags
+	return &pb.Task{
+		Id:              int64(task.ID),
+		Patterns:        task.Patterns,
+		Lines:           bytesFromStrings(task.Lines),
+		BeforeContext:   bytesFromStrings(task.BeforeContext),
+		AfterContext:    bytesFromStrings(task.AfterContext),
+		StartLineNumber: int64(task.StartLineNumber),
+		Flags: &pb.Flags{
+			FixedString:  f.FixedString,
+			PrintNumbers: f.PrintNumbers,
+			IgnoreCase:   f.IgnoreCase,
+			Invert:       f.Invert,
+			After:        int32(f.After),
+			Before:       int32(f.Before),
+			CountOnly:    f.CountOnly,
+			WordMatch:    f.WordMatch,
+			LineMatch:    f.LineMatch,
+			MaxCount:     int32(f.MaxCount),
+			MatchOffsets: f.MatchOffsets,
+		},
+		Path:   task.Path,
+		Offset: task.Offset,
+		Length: task.Length,
+		Size:   task.Size,
+	}
+}
+
+// blockFromPB converts a found block received over gRPC
+func blockFromPB(b *pb.FoundBlock) models.FoundBlock {
+	block := models.FoundBlock{
+		StartLineNumber: int(b.GetStartLineNumber()),
+		Lines:           make([]string, len(b.GetLines())),
+		Matches:         b.GetMatches(),
+	}
+	for i, line := range b.GetLines() {
+		block.Lines[i] = string(line)
+	}
+	if len(b.GetOffsets()) > 0 {
+		block.Offsets = make([][][2]int, len(b.GetOffsets()))
+		for i, o := range b.GetOffsets() {
+			bounds := o.GetBounds()
+			for j := 0; j+1 < len(bounds); j += 2 {
+				block.Offsets[i] = append(block.Offsets[i], [2]int{int(bounds[j]), int(bounds[j+1])})
+			}
+		}
+	}
+	return block
+}
+
+// bytesFromStrings converts lines for sending as bytes
+func bytesFromStrings(lines []string) [][]byte {
+	b := make([][]byte, len(lines))
+	for i, s := range lines {
+		b[i] = []byte(s)
+	}
+	return b
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"client/internal/models"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// output is the destination shared by the printers of all inputs
+type output struct {
+	w      io.Writer
+	errw   io.Writer // for notes such as binary files matching
+	colors palette   // the zero palette leaves output uncolored
+	// grouped is set once a group of lines has been printed, for grep's "--" separators
+	grouped bool
+}
+
+// printer writes the results of one input in line order as they arrive.
+// Blocks from neighbouring chunks may overlap through their context lines,
+// so lines that were already printed are skipped.
+type printer struct {
+	out      *output
+	filename string
+	flags    models.GrepFlags
+	prefix   bool     // prefix every output line with the file name
+	list     ListMode // list the file name instead of printing lines
+	only     bool     // print only the matched parts of lines
+
+	last      int // number of the last printed line
+	printed   bool
+	nselected int // number of selected lines printed
+	lastSel   int // number of the last selected line
+	count     int
+	binary    bool // lines are no longer printed as the input turned out to be binary
+	binaryHit bool // a selected line was found in binary data
+}
+
+// newPrinter creates a printer for the given input.
+func newPrinter(out *output, filename string, flags models.GrepFlags, prefix bool, opts Options) *printer {
+	if filename == "-" {
+		filename = "(standard input)"
+	}
+	return &printer{out: out, filename: filename, flags: flags, prefix: prefix, list: opts.List, only: opts.OnlyMatching}
+}
+
+// counting reports whether blocks carry counts rather than lines
+func (p *printer) counting() bool {
+	return p.flags.CountOnly || p.list != ListNone
+}
+
+// Add prints the blocks of the next chunk. Blocks must be passed in chunk order.
+func (p *printer) Add(blocks []models.FoundBlock) {
+	if p.counting() {
+		// count-only and list modes: sum counts from all chunks
+		for _, b := range blocks {
+			for _, s := range b.Lines {
+				n, err := strconv.Atoi(strings.TrimSpace(s))
+				if err == nil {
+					p.count += n
+				}
+			}
+		}
+		// servers cap the count of each chunk, but not the total
+		if p.flags.MaxCount > 0 {
+			p.count = min(p.count, p.flags.MaxCount)
+		}
+		return
+	}
+
+	colors := p.out.colors
+	context := p.flags.Before > 0 || p.flags.After > 0
+	for _, b := range blocks {
+		for k, s := range b.Lines {
+			ln := b.StartLineNumber + k
+			if p.printed && ln <= p.last {
+				continue
+			}
+			selected := k >= len(b.Matches) || b.Matches[k]
+			if p.limitReached() {
+				// like grep, only trailing context follows the last allowed selected line
+				if ln > p.lastSel+p.flags.After {
+					return
+				}
+				selected = false
+			}
+			if p.binary {
+				// like grep, binary data is not printed; the first selected line ends the input
+				if selected {
+					fmt.Fprintf(p.out.errw, "%s: binary file matches\n", p.filename)
+					p.nselected++
+					p.binaryHit = true
+					return
+				}
+				continue
+			}
+			// like grep, separate non-adjacent groups when context is requested
+			if context && p.out.grouped && (!p.printed || ln > p.last+1) {
+				fmt.Fprintln(p.out.w, colors.paint(colors.separator, "--"))
+			}
+
+			// grep marks selected lines with ':' and context lines with '-'
+			sep, match := "-", colors.contextMatch
+			if selected {
+				sep, match = ":", colors.selectedMatch
+			}
+			// like grep, matches are shown in selected lines, or in context lines with -v
+			var offsets [][2]int
+			if selected != p.flags.Invert {
+				offsets = lineOffsets(b, k)
+			}
+			if p.only {
+				// one output line per match; lines without matches print nothing
+				for _, o := range offsets {
+					fmt.Fprintln(p.out.w, p.linePrefix(ln, sep)+colors.paint(match, s[o[0]:o[1]]))
+				}
+			} else {
+				fmt.Fprintln(p.out.w, p.linePrefix(ln, sep)+colors.highlight(match, s, offsets))
+			}
+
+			p.last = ln
+			p.printed = true
+			if selected {
+				p.nselected++
+				p.lastSel = ln
+			}
+			p.out.grouped = true
+		}
+	}
+}
+
+// linePrefix returns the file name and line number fields printed before a line
+func (p *printer) linePrefix(ln int, sep string) string {
+	colors := p.out.colors
+	var prefix strings.Builder
+	if p.prefix {
+		prefix.WriteString(colors.paint(colors.fileName, p.filename))
+		prefix.WriteString(colors.paint(colors.separator, sep))
+	}
+	if p.flags.PrintNumbers {
+		prefix.WriteString(colors.paint(colors.lineNumber, strconv.Itoa(ln)))
+		prefix.WriteString(colors.paint(colors.separator, sep))
+	}
+	return prefix.String()
+}
+
+// lineOffsets returns the match offsets of the k-th line of b,
+// dropping any that are out of order or out of range
+func lineOffsets(b models.FoundBlock, k int) [][2]int {
+	if k >= len(b.Offsets) {
+		return nil
+	}
+	offsets := make([][2]int, 0, len(b.Offsets[k]))
+	prev := 0
+	for _, o := range b.Offsets[k] {
+		if o[0] < prev || o[1] <= o[0] || o[1] > len(b.Lines[k]) {
+			continue
+		}
+		offsets = append(offsets, o)
+		prev = o[1]
+	}
+	return offsets
+}
+
+// Selected reports whether any line was selected so far.
+func (p *printer) Selected() bool {
+	if p.counting() {
+		return p.count > 0
+	}
+	return p.nselected > 0
+}
+
+// SetBinary stops printing lines, as the input turned out to hold binary data.
+// Selected lines are then only reported by a note on the first one.
+func (p *printer) SetBinary() {
+	p.binary = true
+}
+
+// Discard takes the input not to match after all, as grep -I does with binary data.
+// Lines already printed stay printed.
+func (p *printer) Discard() {
+	p.count = 0
+	p.nselected = 0
+}
+
+// limitReached reports whether as many lines were selected as -m allows
+func (p *printer) limitReached() bool {
+	if p.flags.MaxCount <= 0 {
+		return false
+	}
+	if p.counting() {
+		return p.count >= p.flags.MaxCount
+	}
+	return p.nselected >= p.flags.MaxCount
+}
+
+// Done reports whether further chunks of the input can no longer change the output.
+func (p *printer) Done() bool {
+	if p.list != ListNone && p.count > 0 || p.binaryHit {
+		return true
+	}
+	if !p.limitReached() {
+		return false
+	}
+	// the last selected line may have arrived as context of the previous chunk,
+	// in which case its trailing context only comes with its own chunk
+	return p.counting() || p.last >= p.lastSel+p.flags.After
+}
+
+// Finish prints the per-input summary, if any.
+func (p *printer) Finish() {
+	colors := p.out.colors
+	switch {
+	case p.list == ListMatches:
+		if p.count > 0 {
+			fmt.Fprintln(p.out.w, colors.paint(colors.fileName, p.filename))
+		}
+	case p.list == ListNonMatches:
+		if p.count == 0 {
+			fmt.Fprintln(p.out.w, colors.paint(colors.fileName, p.filename))
+		}
+	case !p.flags.CountOnly:
+	case p.prefix:
+		fmt.Fprintf(p.out.w, "%s%s%d\n", colors.paint(colors.fileName, p.filename), colors.paint(colors.separator, ":"), p.count)
+	default:
+		fmt.Fprintf(p.out.w, "%d\n", p.count)
+	}
+}
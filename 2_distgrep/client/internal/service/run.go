@@ -1,214 +1,403 @@
 package service
 
 import (
-	"bufio"
-	"bytes"
+	"client/internal/helpers/decompress"
 	"client/internal/helpers/parser"
+	"client/internal/helpers/walk"
 	"client/internal/models"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"io"
+	"io/fs"
+	"iter"
 	"os"
-	"sort"
-	"strconv"
-	"strings"
-	"sync"
+	"time"
 )
 
-// Run is the main function for running the grep service
-func Run(pattern string, files []string, addrs []string, flags models.GrepFlags, quorum int) error {
+// Defaults for the corresponding Options fields
+const (
+	DefaultReplicas     = 1
+	DefaultChunkLines   = 10000
+	DefaultChunkBytes   = 4 << 20
+	DefaultCompress     = CompressNone
+	DefaultFraming      = FramingJSON
+	DefaultRetries      = 3
+	DefaultRetryBackoff = 100 * time.Millisecond
+	DefaultTimeout      = 30 * time.Second
+)
+
+// NameMode controls whether output lines are prefixed with file names
+type NameMode int
+
+// Possible file name modes
+const (
+	NamesAuto   NameMode = iota // when more than one file may be searched
+	NamesAlways                 // always, like grep -H
+	NamesNever                  // never, like grep -h
+)
+
+// ListMode selects listing file names instead of printing lines
+type ListMode int
+
+// Possible list modes
+const (
+	ListNone       ListMode = iota // print lines
+	ListMatches                    // list files with selected lines, like grep -l
+	ListNonMatches                 // list files without selected lines, like grep -L
+)
+
+// BinaryMode selects how inputs holding binary data are handled
+type BinaryMode int
+
+// Possible binary modes, named after grep's --binary-files values
+const (
+	BinaryMatches      BinaryMode = iota // report that the input matches instead of printing lines
+	BinaryText                           // process binary data as text, like grep -a
+	BinaryWithoutMatch                   // assume binary data does not match, like grep -I
+)
+
+// Options holds the settings of a grep run that are not sent to servers
+type Options struct {
+	Recursive    bool          // search directories recursively
+	Filter       walk.Filter   // file name filters applied to searched files
+	Names        NameMode      // whether to prefix output lines with file names
+	List         ListMode      // whether to list file names instead of printing lines
+	OnlyMatching bool          // print only the matched parts of lines, one per output line
+	Color        bool          // highlight output with the colors set in GREP_COLORS
+	Decompress   bool          // fail on inputs that are not compressed instead of reading them as is
+	Binary       BinaryMode    // how to handle inputs holding binary data
+	Addrs        []string      // server addresses
+	Replicas     int           // number of distinct servers each task is sent to
+	Quorum       int           // minimum number of replicas that must return identical results (default: majority)
+	ChunkLines   int           // number of lines sent per task
+	Compress     string        // content coding of task and result bodies over HTTP: CompressNone, CompressGzip or CompressZstd
+	Framing      string        // framing of task and result bodies over HTTP: FramingJSON or FramingProtobuf
+	CACert       string        // file of CA certificates to verify servers with instead of the system roots
+	Cert         string        // file of the client certificate presented to servers requiring one
+	Key          string        // file of the private key of Cert, if not in Cert itself
+	Token        string        // bearer token sent to servers requiring one, over https and grpcs only
+	PlainToken   bool          // also send Token over http and grpc, in plain text
+	Remote       bool          // files are read by the servers from under their roots rather than sent to them
+	ChunkBytes   int64         // size of the byte range of a file searched per task, with Remote
+	Shards       bool          // with Remote, every server holds its own shard of the files rather than a copy
+	Retries      int           // number of times a failed task is re-sent to another server
+	RetryBackoff time.Duration // delay before the first retry, doubled on every further retry
+	Timeout      time.Duration // time a server has to answer one request before it is retried elsewhere, 0 for no limit
+}
+
+// chunkResult is the accepted result of one task
+type chunkResult struct {
+	result       models.Result
+	err          error
+	disagreement error // set if some replicas returned another result than the accepted one
+}
+
+// Status is the outcome of a grep run; its values are grep's exit codes
+type Status int
+
+// Possible outcomes of a grep run
+const (
+	StatusSelected   Status = 0 // at least one line was selected
+	StatusNoneFound  Status = 1 // no line was selected
+	StatusInputError Status = 2 // an input could not be searched
+)
+
+// Run is the main function for running the grep service.
+// A line is selected if it matches any of patterns.
+// Errors for individual inputs are written to stderr and reported through the status,
+// like grep does; the returned error is reserved for failures that prevent the whole run.
+func Run(patterns []string, files []string, flags models.GrepFlags, opts Options) (Status, error) {
 	Err := os.Stderr
-	aliveServers := make([]*models.ParsedAddr, 0, len(addrs))
-	for i := range addrs {
-		parsed, err := parser.ParseAddress(addrs[i], "http")
-		if err != nil {
-			return err
-		}
+	if opts.Compress == "" {
+		opts.Compress = DefaultCompress
+	}
+	if opts.Framing == "" {
+		opts.Framing = DefaultFraming
+	}
+	t, err := newTransport(opts)
+	if err != nil {
+		return StatusInputError, fmt.Errorf("failed to set up transport security: %w", err)
+	}
 
-		req, err := http.NewRequest("GET", "http://"+parsed.Host+":"+parsed.Port+"/health", nil)
+	aliveServers := make([]*models.ParsedAddr, 0, len(opts.Addrs))
+	for i := range opts.Addrs {
+		parsed, err := parser.ParseAddress(opts.Addrs[i], schemeHTTP)
 		if err != nil {
-			return err
+			return StatusInputError, err
+		}
+		if err := checkScheme(parsed); err != nil {
+			return StatusInputError, err
+		}
+		if err := t.checkToken(parsed); err != nil {
+			return StatusInputError, err
 		}
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent) {
+
+		if t.checkHealth(parsed) == nil {
 			aliveServers = append(aliveServers, parsed)
+		} else if opts.Shards {
+			// no other server holds its shard
+			return StatusInputError, fmt.Errorf("server %s is not alive, its shards cannot be searched", opts.Addrs[i])
 		} else {
-			fmt.Fprintf(Err, "server %s is not alive\n", addrs[i])
-		}
-		if resp != nil {
-			resp.Body.Close()
+			fmt.Fprintf(Err, "server %s is not alive\n", opts.Addrs[i])
 		}
 	}
 	if len(aliveServers) == 0 {
-		return fmt.Errorf("no alive servers found")
+		return StatusInputError, fmt.Errorf("no alive servers found")
 	}
 
-	if quorum <= 0 || quorum > len(aliveServers) {
-		quorum = len(aliveServers)/2 + 1
+	if opts.Replicas <= 0 || opts.Shards {
+		opts.Replicas = DefaultReplicas
+	}
+	if opts.Replicas > len(aliveServers) {
+		fmt.Fprintf(Err, "only %d servers alive, using %d replicas instead of %d\n", len(aliveServers), len(aliveServers), opts.Replicas)
+		opts.Replicas = len(aliveServers)
 	}
-	wg := new(sync.WaitGroup)
+	if opts.Quorum <= 0 || opts.Quorum > opts.Replicas {
+		opts.Quorum = opts.Replicas/2 + 1
+	}
+	if opts.ChunkLines <= 0 {
+		opts.ChunkLines = DefaultChunkLines
+	}
+	if opts.ChunkBytes <= 0 {
+		opts.ChunkBytes = DefaultChunkBytes
+	}
+	opts.Retries = max(opts.Retries, 0)
 
-	for i, file := range files {
-		lines, err := openInput(file)
-		if err != nil {
-			return err
-		}
+	servers := newPool(aliveServers, t)
+	showNames := opts.Names == NamesAlways || opts.Names == NamesAuto &&
+		(walk.ShowNames(files, opts.Recursive) || opts.Shards && len(aliveServers) > 1)
+	selected, failed := grepInputs(walk.Files(files, opts.Recursive, opts.Filter), patterns, flags, servers, opts, showNames)
 
-		tasks := createTasksWithContext(lines, pattern, flags, len(aliveServers), i)
+	status := StatusNoneFound
+	if selected {
+		status = StatusSelected
+	}
+	// like grep, errors take precedence over matches
+	if failed {
+		status = StatusInputError
+	}
+	return status, nil
+}
 
-		allBlocks := make([]models.FoundBlock, 0, len(tasks))
-		var mu sync.Mutex
-		var successCount int
+// input tracks one searched file through the pipeline
+type input struct {
+	name    string
+	printer *printer
+	err     error // set before the item closing the input is queued
+	sent    int
+	failed  int
+	binary  bool // binary data was found in the input so far
+	lines   int  // number of lines in the chunks printed so far, for numbering lines read by servers
+	// number of chunks whose accepted result some replicas disagreed with
+	disagreed int
 
-		for j, task := range tasks {
-			addr := aliveServers[j%len(aliveServers)]
-			wg.Add(1)
-			go func(addr *models.ParsedAddr, task models.Task) {
-				defer wg.Done()
+	// cancelling ctx stops reading and searching the input once its outcome is known
+	ctx    context.Context
+	cancel context.CancelFunc
+}
 
-				data, err := json.Marshal(task)
-				if err != nil {
-					fmt.Fprintf(Err, "failed to marshal request: %v\n", err)
-					return
-				}
+// item is a unit of output: a chunk of an input, or the end of the input if res is nil
+type item struct {
+	in     *input
+	res    chan chunkResult
+	binary bool // binary data was found in the input up to the end of the chunk
+}
 
-				resp, err := http.Post("http://"+addr.Host+":"+addr.Port+"/grep", "application/json", bytes.NewBuffer(data))
-				if err != nil {
-					fmt.Fprintf(Err, "failed to send request to %s: %v\n", addr.Host+":"+addr.Port, err)
-					return
-				}
-				defer resp.Body.Close()
+// grepInputs streams the inputs to the servers chunk by chunk and prints the results in order.
+// Chunks are spread round-robin across servers regardless of which input they belong to,
+// so many small files are searched in parallel just like the chunks of one big file.
+// With opts.Shards, the shard of a file held by each server is a separate input,
+// searched by that server only and named after it.
+// It reports whether any line was selected and whether any input failed.
+func grepInputs(files iter.Seq2[string, error], patterns []string, flags models.GrepFlags, servers *pool, opts Options, showNames bool) (selected, failed bool) {
+	out := &output{w: os.Stdout, errw: os.Stderr}
+	if opts.Color {
+		out.colors = newPalette(os.Getenv("GREP_COLORS"))
+	}
 
-				if resp.StatusCode != http.StatusOK {
-					fmt.Fprintf(Err, "server %s returned status %d\n", addr.Host+":"+addr.Port, resp.StatusCode)
-					return
-				}
+	// line numbers are added by the printer
+	taskFlags := flags
+	taskFlags.PrintNumbers = false
+	taskFlags.MatchOffsets = opts.OnlyMatching || opts.Color
+	if opts.List != ListNone {
+		// listing file names only needs to know whether a line was selected
+		taskFlags.CountOnly = true
+		taskFlags.Before, taskFlags.After = 0, 0
+	}
 
-				var result models.Result
-				if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-					fmt.Fprintf(Err, "failed to decode response from %s: %v\n", addr.Host+":"+addr.Port, err)
-					return
-				}
+	// items are consumed in the order they were read; the queue capacity bounds
+	// the number of chunks in flight, and with it memory use
+	queue := make(chan item, 2*len(servers.servers))
+	go func() {
+		defer close(queue)
+		seq := 0
+		// search queues the chunks of the named input, sent to the servers of p,
+		// and then its end; label names the input in the output
+		search := func(name, label string, p *pool, err error) {
+			in := &input{
+				name:    label,
+				printer: newPrinter(out, label, flags, showNames, opts),
+			}
+			in.ctx, in.cancel = context.WithCancel(context.Background())
+			dispatch := func(task models.Task, binary bool) {
+				task.ID = seq
+				res := make(chan chunkResult, 1)
+				queue <- item{in: in, res: res, binary: binary}
+				go func(seq int) {
+					res <- p.replicate(in.ctx, seq, task, opts, os.Stderr)
+				}(seq)
+				seq++
+			}
+			switch {
+			case err != nil:
+			case opts.Remote:
+				err = readRemote(in.ctx, name, p, patterns, taskFlags, opts.ChunkBytes, dispatch)
+			default:
+				err = readInput(in.ctx, name, patterns, taskFlags, opts.ChunkLines, opts.Decompress, dispatch)
+			}
+			in.err = err
+			queue <- item{in: in}
+		}
 
-				mu.Lock()
-				allBlocks = append(allBlocks, result.FoundBlocks...)
-				successCount++
-				mu.Unlock()
-			}(addr, task)
+		for name, err := range files {
+			if !opts.Shards || err != nil {
+				search(name, name, servers, err)
+				continue
+			}
+			for _, addr := range servers.servers {
+				search(name, name+"@"+addr.Host+":"+addr.Port, newPool([]*models.ParsedAddr{addr}, servers.transport), nil)
+			}
 		}
-		wg.Wait()
-
-		if successCount >= quorum {
-			// Special aggregation for count-only: sum counts from all servers
-			if flags.CountOnly {
-				total := 0
-				for _, b := range allBlocks {
-					for _, s := range b.Lines {
-						n, err := strconv.Atoi(strings.TrimSpace(s))
-						if err == nil {
-							total += n
-						}
-					}
-				}
-				if len(files) > 1 {
-					fmt.Printf("%s:%d\n", file, total)
-				} else {
-					fmt.Printf("%d\n", total)
-				}
+	}()
+
+	for it := range queue {
+		in := it.in
+		if it.res != nil {
+			r := <-it.res
+			if in.ctx.Err() != nil {
+				// the outcome is already known, remaining chunks were abandoned
 				continue
 			}
+			// binary data is found while reading, or by the servers reading the input
+			in.binary = in.binary || it.binary || r.result.Binary
+			if in.binary && opts.Binary == BinaryWithoutMatch {
+				// like grep, the whole input is then taken not to match
+				in.printer.Discard()
+				in.cancel()
+				continue
+			}
+			in.sent++
+			if r.err != nil {
+				in.failed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", in.name, r.err)
+				continue
+			}
+			if r.disagreement != nil {
+				// the accepted result is still printed, but the run fails
+				in.disagreed++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", in.name, r.disagreement)
+			}
+			if opts.Remote {
+				// servers number the lines of a byte range from 0; chunks arrive in order
+				for i := range r.result.FoundBlocks {
+					r.result.FoundBlocks[i].StartLineNumber += in.lines + 1
+				}
+				in.lines += r.result.LineCount
+			}
+			if in.binary && opts.Binary == BinaryMatches {
+				in.printer.SetBinary()
+			}
+			in.printer.Add(r.result.FoundBlocks)
+			if in.printer.Done() {
+				in.cancel()
+			}
+			continue
+		}
 
-			printBlocksNonOverlapping(file, allBlocks, flags, len(files) > 1)
-		} else {
-			return fmt.Errorf("quorum not reached for %s: got %d, need %d", file, successCount, quorum)
+		in.cancel()
+		switch {
+		case in.err != nil:
+			fmt.Fprintln(os.Stderr, in.err)
+			failed = true
+		case in.failed > 0:
+			fmt.Fprintf(os.Stderr, "%s: %d of %d chunks failed\n", in.name, in.failed, in.sent)
+			failed = true
+		default:
+			in.printer.Finish()
+			failed = failed || in.disagreed > 0
 		}
+		selected = selected || in.printer.Selected()
 	}
-	return nil
-}
-
-// createTasksWithContext creates tasks with context for each server
-func createTasksWithContext(lines []string, pattern string, flags models.GrepFlags, numServers, offset int) []models.Task {
-	out := make([]models.Task, 0, numServers)
-	ctxB := flags.Before
-	ctxA := flags.After
-	for i := range numServers {
-		left := i * len(lines) / numServers
-		right := min((i+1)*len(lines)/numServers, len(lines))
-		Lines := lines[left:right]
-		before := lines[max(0, left-ctxB):left]
-		after := lines[right:min(right+ctxA, len(lines))]
-		out = append(out, models.Task{
-			Pattern:         pattern,
-			Lines:           Lines,
-			ID:              i + offset*numServers,
-			BeforeContext:   before,
-			AfterContext:    after,
-			StartLineNumber: left + 1,
-			Flags:           flags,
-		})
-	}
-	return out
+	return selected, failed
 }
 
-// openFile opens a file and returns its lines
-func openFile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+// readInput splits the named input into tasks and passes them to dispatch in order.
+// Compressed inputs are decompressed; with forceDecompress, inputs that are not are an error.
+// It stops early once ctx is cancelled.
+func readInput(ctx context.Context, name string, patterns []string, flags models.GrepFlags, chunkLines int, forceDecompress bool, dispatch func(task models.Task, binary bool)) error {
+	f, err := openInput(name)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	defer f.Close()
+	in, err := decompress.NewReader(f, forceDecompress)
+	if err != nil {
+		return inputError(name, err)
 	}
-	return lines, scanner.Err()
-}
+	defer in.Close()
 
-// openInput opens an input file or stdin
-func openInput(name string) ([]string, error) {
-	if name == "-" {
-		scanner := bufio.NewScanner(os.Stdin)
-		lines := make([]string, 0)
-		for scanner.Scan() {
-			lines = append(lines, scanner.Text())
+	chunks := newChunker(in, patterns, flags, chunkLines)
+	for ctx.Err() == nil {
+		task, ok, err := chunks.Next()
+		if err != nil {
+			return inputError(name, err)
 		}
-		return lines, scanner.Err()
+		if !ok {
+			return nil
+		}
+		dispatch(task, chunks.Binary())
 	}
-	return openFile(name)
+	return nil
 }
 
-// printBlocksNonOverlapping prints blocks of lines non-overlapping
-func printBlocksNonOverlapping(filename string, blocks []models.FoundBlock, flags models.GrepFlags, printFileName bool) {
-	if len(blocks) == 0 {
-		return
+// readRemote splits a file held by the servers into tasks of byte ranges and passes them
+// to dispatch in order. The servers read the lines of each range themselves.
+// It stops early once ctx is cancelled.
+func readRemote(ctx context.Context, name string, servers *pool, patterns []string, flags models.GrepFlags, chunkBytes int64, dispatch func(task models.Task, binary bool)) error {
+	info, err := servers.stat(ctx, name)
+	if err != nil {
+		return inputError(name, err)
 	}
-
-	lineToText := make(map[int]string, 1024)
-	for _, b := range blocks {
-		for k, s := range b.Lines {
-			ln := b.StartLineNumber + k
-			if _, exists := lineToText[ln]; !exists {
-				lineToText[ln] = s
-			}
-		}
+	for offset := int64(0); offset < info.Size && ctx.Err() == nil; offset += chunkBytes {
+		dispatch(models.Task{
+			Patterns: patterns,
+			Flags:    flags,
+			Path:     name,
+			Offset:   offset,
+			Length:   min(chunkBytes, info.Size-offset),
+			Size:     info.Size,
+		}, false)
 	}
+	return nil
+}
 
-	keys := make([]int, 0, len(lineToText))
-	for ln := range lineToText {
-		keys = append(keys, ln)
+// inputError names the input in err unless err already names a path, as os errors do
+func inputError(name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return err
 	}
-	sort.Ints(keys)
-
-	if printFileName {
-		fmt.Println(filename)
+	if name == "-" {
+		name = "(standard input)"
 	}
-	for _, ln := range keys {
-		if flags.PrintNumbers {
-			fmt.Printf("%d:%s\n", ln, lineToText[ln])
-		} else {
-			fmt.Println(lineToText[ln])
-		}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// openInput opens an input file or stdin
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
 	}
+	return os.Open(name)
 }
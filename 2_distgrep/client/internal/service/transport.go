@@ -0,0 +1,145 @@
+package service
+
+import (
+	"client/internal/models"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Schemes of server addresses besides http, which is assumed for addresses without one
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+	schemeGRPC  = "grpc"  // gRPC without TLS, as in grpc://host:port
+	schemeGRPCS = "grpcs" // gRPC over TLS
+)
+
+// Framings of task and result bodies on the HTTP transport
+const (
+	FramingJSON     = "json"
+	FramingProtobuf = "protobuf" // a Task and a Result message of the gRPC protocol
+)
+
+// mimeProtobuf is the Content-Type of bodies framed as protobuf messages
+const mimeProtobuf = "application/x-protobuf"
+
+// healthTimeout bounds the health check of a server, which would otherwise
+// wait for an answer as long as its context allows
+const healthTimeout = 5 * time.Second
+
+// transport holds what every request to the servers shares: the TLS configuration,
+// the bearer token, the framing and coding of HTTP bodies and the connections to gRPC servers
+type transport struct {
+	http     *http.Client
+	tls      *tls.Config
+	token    string
+	plain    bool // the token may also be sent over http and grpc
+	framing  string
+	compress string
+	timeout  time.Duration // bound of every request to a server, 0 for none
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+// newTransport creates the transport for the security settings of opts.
+// Without CACert, server certificates are verified against the system roots.
+func newTransport(opts Options) (*transport, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CACert != "" {
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CACert)
+		}
+	}
+	if opts.Cert != "" {
+		key := opts.Key
+		if key == "" {
+			// the key may be stored in the same file as the certificate
+			key = opts.Cert
+		}
+		cert, err := tls.LoadX509KeyPair(opts.Cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = cfg
+	return &transport{
+		http:     &http.Client{Transport: base},
+		tls:      cfg,
+		token:    opts.Token,
+		plain:    opts.PlainToken,
+		framing:  opts.Framing,
+		compress: opts.Compress,
+		timeout:  opts.Timeout,
+		conns:    make(map[string]*grpc.ClientConn),
+	}, nil
+}
+
+// checkScheme returns an error if addr uses a scheme no transport is known for
+func checkScheme(addr *models.ParsedAddr) error {
+	switch addr.Scheme {
+	case schemeHTTP, schemeHTTPS, schemeGRPC, schemeGRPCS:
+		return nil
+	}
+	return fmt.Errorf("unsupported scheme %q in server address %s", addr.Scheme, addr.Raw)
+}
+
+// checkToken returns an error if the bearer token of t would be sent to addr in plain text
+func (t *transport) checkToken(addr *models.ParsedAddr) error {
+	if t.token == "" || t.plain || addr.Scheme == schemeHTTPS || addr.Scheme == schemeGRPCS {
+		return nil
+	}
+	return fmt.Errorf("refusing to send the token to %s over plain %s; use https or grpcs, or allow it with --insecure-token", addr.Raw, addr.Scheme)
+}
+
+// isGRPC reports whether addr is served over gRPC
+func isGRPC(addr *models.ParsedAddr) bool {
+	return addr.Scheme == schemeGRPC || addr.Scheme == schemeGRPCS
+}
+
+// baseURL returns the URL of the HTTP server at addr, to which endpoint paths are appended
+func baseURL(addr *models.ParsedAddr) string {
+	return addr.Scheme + "://" + addr.Host + ":" + addr.Port
+}
+
+// withTimeout returns the context of one request to a server, bounded by the timeout of t if any
+func (t *transport) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, t.timeout)
+}
+
+// timeoutError returns the error of a request to addr made with the context returned by withTimeout.
+// A request that ran out of time is reported as such, as a failure of the server rather than of
+// the run: unlike parent, attempt is done then.
+func (t *transport) timeoutError(parent, attempt context.Context, addr *models.ParsedAddr, err error) error {
+	if err != nil && parent.Err() == nil && errors.Is(attempt.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("server %s:%s did not answer within %v", addr.Host, addr.Port, t.timeout)
+	}
+	return err
+}
+
+// authorize adds the bearer token, if any, to an HTTP request, unless it would go in plain text
+func (t *transport) authorize(req *http.Request) {
+	if t.token != "" && (t.plain || req.URL.Scheme == schemeHTTPS) {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+}
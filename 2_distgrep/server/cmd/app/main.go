@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"grep-server/internal/delivery"
@@ -9,14 +10,24 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 )
 
 func main() {
 	var port int
 	var daemon bool
+	var grpcPort int
+	var root string
+	var tlsCert, tlsKey, clientCA, tokenFile string
 	flag.BoolVar(&daemon, "d", false, "run as daemon")
 	flag.IntVar(&port, "port", 8080, "port to listen on")
+	flag.IntVar(&grpcPort, "grpc-port", 0, "port to serve gRPC on (disabled if 0)")
+	flag.StringVar(&tlsCert, "tls-cert", "", "certificate file to serve HTTPS and gRPC over TLS with (needs -tls-key)")
+	flag.StringVar(&tlsKey, "tls-key", "", "private key file of the -tls-cert certificate")
+	flag.StringVar(&clientCA, "client-ca", "", "CA certificates file; clients must present a certificate signed by one of them (needs -tls-cert)")
+	flag.StringVar(&tokenFile, "token-file", "", "file holding the bearer token clients must send (disabled if empty)")
+	flag.StringVar(&root, "root", "", "directory whose files clients may search by path (disabled if empty)")
 	flag.Parse()
 
 	if daemon {
@@ -31,7 +42,8 @@ func main() {
 		f := tmpFile
 		logFilePath := f.Name()
 
-		cmd := exec.Command(os.Args[0], fmt.Sprintf("-port=%d", port))
+		cmd := exec.Command(os.Args[0], fmt.Sprintf("-port=%d", port), fmt.Sprintf("-grpc-port=%d", grpcPort), "-root="+root,
+			"-tls-cert="+tlsCert, "-tls-key="+tlsKey, "-client-ca="+clientCA, "-token-file="+tokenFile)
 		cmd.SysProcAttr = &syscall.SysProcAttr{
 			Setsid: true,
 		}
@@ -54,7 +66,28 @@ func main() {
 		os.Exit(0)
 	}
 
-	srv := delivery.NewServer(service.NewService())
+	srvc, err := service.NewService(root)
+	if err != nil {
+		log.Fatalf("failed to open root directory: %v", err)
+	}
+	opts, err := securityOptions(tlsCert, tlsKey, clientCA, tokenFile)
+	if err != nil {
+		log.Fatalf("failed to set up transport security: %v", err)
+	}
+	if opts.Token != "" && opts.TLS == nil {
+		log.Printf("warning: -token-file without -tls-cert lets the token travel in plain text")
+	}
+
+	if grpcPort != 0 {
+		go func() {
+			log.Printf("gRPC server started on port %d", grpcPort)
+			if err := delivery.NewGRPCServer(srvc, opts).Start(grpcPort); err != nil {
+				log.Fatalf("failed to start gRPC server on port %d: %v", grpcPort, err)
+			}
+		}()
+	}
+
+	srv := delivery.NewServer(srvc, opts)
 	for err := srv.Start(port); err != nil && port < 65535; func() {
 		port++
 		err = srv.Start(port)
@@ -64,3 +97,32 @@ func main() {
 
 	log.Printf("server started on port %d", port)
 }
+
+// securityOptions loads the TLS configuration and token named by the flags
+func securityOptions(tlsCert, tlsKey, clientCA, tokenFile string) (delivery.Options, error) {
+	var opts delivery.Options
+	switch {
+	case tlsCert != "" || tlsKey != "":
+		if tlsCert == "" || tlsKey == "" {
+			return opts, errors.New("-tls-cert and -tls-key must be given together")
+		}
+		cfg, err := delivery.LoadTLS(tlsCert, tlsKey, clientCA)
+		if err != nil {
+			return opts, err
+		}
+		opts.TLS = cfg
+	case clientCA != "":
+		return opts, errors.New("-client-ca needs -tls-cert and -tls-key")
+	}
+	if tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Token = strings.TrimSpace(string(data))
+		if opts.Token == "" {
+			return opts, fmt.Errorf("%s holds no token", tokenFile)
+		}
+	}
+	return opts, nil
+}
@@ -2,7 +2,12 @@ module grep-server
 
 go 1.25.0
 
-require github.com/labstack/echo/v4 v4.13.4
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/labstack/echo/v4 v4.13.4
+	google.golang.org/grpc v1.79.3
+	google.golang.org/protobuf v1.36.11
+)
 
 require (
 	github.com/labstack/gommon v0.4.2 // indirect
@@ -10,8 +15,9 @@ require (
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
-	golang.org/x/crypto v0.38.0 // indirect
-	golang.org/x/net v0.40.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.25.0 // indirect
+	golang.org/x/crypto v0.46.0 // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
 )
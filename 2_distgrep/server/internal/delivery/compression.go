@@ -0,0 +1,120 @@
+package delivery
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+// Content codings supported for request and response bodies, in order of preference
+const (
+	codingZstd = "zstd"
+	codingGzip = "gzip"
+)
+
+// maxDecodedBody bounds the decompressed size of a request body, so that a small
+// compressed body cannot make the server buffer gigabytes
+const maxDecodedBody = 256 << 20
+
+// encoders are reused across responses, as creating them costs more than compressing a small body
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdWriters = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// compression decompresses request bodies sent with a Content-Encoding of gzip or zstd,
+// and compresses responses with the preferred of these the client accepts.
+// Requests in another coding are rejected with 415, so that clients may fall back to none.
+// Decompressed bodies longer than maxDecodedBody fail to read with an *http.MaxBytesError.
+func compression(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		switch coding := req.Header.Get(echo.HeaderContentEncoding); coding {
+		case "", "identity":
+		case codingGzip:
+			r, err := gzip.NewReader(req.Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+			}
+			defer r.Close()
+			req.Body = http.MaxBytesReader(c.Response(), r, maxDecodedBody)
+		case codingZstd:
+			r, err := zstd.NewReader(req.Body, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+			}
+			defer r.Close()
+			req.Body = http.MaxBytesReader(c.Response(), io.NopCloser(r), maxDecodedBody)
+		default:
+			return c.JSON(http.StatusUnsupportedMediaType, echo.Map{"error": fmt.Sprintf("unsupported content encoding %q", coding)})
+		}
+
+		res := c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		var w io.WriteCloser
+		switch acceptedCoding(req.Header.Get(echo.HeaderAcceptEncoding)) {
+		case codingZstd:
+			zw := zstdWriters.Get().(*zstd.Encoder)
+			zw.Reset(res.Writer)
+			defer zstdWriters.Put(zw)
+			w = zw
+			res.Header().Set(echo.HeaderContentEncoding, codingZstd)
+		case codingGzip:
+			gw := gzipWriters.Get().(*gzip.Writer)
+			gw.Reset(res.Writer)
+			defer gzipWriters.Put(gw)
+			w = gw
+			res.Header().Set(echo.HeaderContentEncoding, codingGzip)
+		default:
+			return next(c)
+		}
+		res.Writer = &encodingWriter{ResponseWriter: res.Writer, w: w}
+		err := next(c)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
+
+// acceptedCoding returns the preferred coding listed in an Accept-Encoding header, if any
+func acceptedCoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, field := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(field, ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				q, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		// a quality of 0 means not acceptable
+		accepted[strings.TrimSpace(coding)] = q > 0
+	}
+	for _, coding := range []string{codingZstd, codingGzip} {
+		if accepted[coding] {
+			return coding
+		}
+	}
+	return ""
+}
+
+// encodingWriter writes response bodies through an encoder
+type encodingWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (w *encodingWriter) Write(b []byte) (int, error) {
+	return w.w.Write(b)
+}
@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"encoding/base64"
+	"fmt"
+	"grep-server/internal/models"
+	"unicode/utf8"
+)
+
+// decodeRequest replaces base64-encoded lines of req by the bytes they encode
+func decodeRequest(req *models.Request) error {
+	switch req.Encoding {
+	case "":
+		return nil
+	case models.EncodingBase64:
+	default:
+		return fmt.Errorf("%w: unknown encoding %q", models.ErrBadEncoding, req.Encoding)
+	}
+
+	for _, lines := range [][]string{req.Lines, req.BeforeContext, req.AfterContext} {
+		for i, s := range lines {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return fmt.Errorf("%w: %w", models.ErrBadEncoding, err)
+			}
+			lines[i] = string(b)
+		}
+	}
+	req.Encoding = ""
+	return nil
+}
+
+// encodeResponse base64-encodes the block lines of resp if any of them is not valid UTF-8
+func encodeResponse(resp *models.Response) {
+	valid := true
+	for _, b := range resp.FoundBlocks {
+		for _, s := range b.Lines {
+			valid = valid && utf8.ValidString(s)
+		}
+	}
+	if valid {
+		return
+	}
+
+	for _, b := range resp.FoundBlocks {
+		for i, s := range b.Lines {
+			b.Lines[i] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+	}
+	resp.Encoding = models.EncodingBase64
+}
@@ -0,0 +1,159 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"grep-server/internal/models"
+	"grep-server/internal/pb"
+	"math"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer serves the service over gRPC, streaming found blocks as they are built
+type GRPCServer struct {
+	pb.UnimplementedGrepServer
+	srv  *grpc.Server
+	srvc Service
+}
+
+// NewGRPCServer creates a new gRPC server, with the standard health service
+func NewGRPCServer(srvc Service, opts Options) *GRPCServer {
+	// tasks are as large as the lines the client puts in a chunk
+	serverOpts := []grpc.ServerOption{grpc.MaxRecvMsgSize(math.MaxInt32)}
+	if opts.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	}
+	if opts.Token != "" {
+		serverOpts = append(serverOpts, grpcToken(opts.Token)...)
+	}
+	s := &GRPCServer{srv: grpc.NewServer(serverOpts...), srvc: srvc}
+	pb.RegisterGrepServer(s.srv, s)
+	healthpb.RegisterHealthServer(s.srv, health.NewServer())
+	return s
+}
+
+// Search is the handler for the Search method
+func (s *GRPCServer) Search(task *pb.Task, stream grpc.ServerStreamingServer[pb.SearchReply]) error {
+	resp, err := s.srvc.Search(requestFromPB(task), func(b models.FoundBlock) error {
+		return stream.Send(&pb.SearchReply{Reply: &pb.SearchReply_Block{Block: blockToPB(b)}})
+	})
+	if err != nil {
+		return status.Error(errorCode(err), err.Error())
+	}
+	return stream.Send(&pb.SearchReply{Reply: &pb.SearchReply_Summary{Summary: &pb.Summary{
+		TaskId:    int64(resp.TaskID),
+		LineCount: int64(resp.LineCount),
+		Binary:    resp.Binary,
+	}}})
+}
+
+// Stat is the handler for the Stat method
+func (s *GRPCServer) Stat(_ context.Context, req *pb.StatRequest) (*pb.FileInfo, error) {
+	info, err := s.srvc.Stat(req.GetPath())
+	if err != nil {
+		return nil, status.Error(errorCode(err), err.Error())
+	}
+	return &pb.FileInfo{Path: info.Path, Size: info.Size}, nil
+}
+
+// Start starts the gRPC server
+func (s *GRPCServer) Start(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return s.srv.Serve(l)
+}
+
+// errorCode returns the gRPC status code for an error of the service layer, like errorStatus
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, models.ErrSizeMismatch):
+		return codes.FailedPrecondition
+	case errors.Is(err, models.ErrEmptyPattern), errors.Is(err, models.ErrInvalidRegex),
+		errors.Is(err, models.ErrNoRoot), errors.Is(err, models.ErrBadPath), errors.Is(err, models.ErrBadRange),
+		errors.Is(err, models.ErrTooLarge):
+		return codes.InvalidArgument
+	}
+	return status.Code(err)
+}
+
+// requestFromPB converts a task received over gRPC to a request
+func requestFromPB(t *pb.Task) models.Request {
+	f := t.GetFlags()
+	// tasks always carry a pattern list, but protobuf does not tell an empty one from none
+	patterns := t.GetPatterns()
+	if patterns == nil {
+		patterns = []string{}
+	}
+	return models.Request{
+		ID:              int(t.GetId()),
+		Patterns:        patterns,
+		Lines:           stringsFromBytes(t.GetLines()),
+		BeforeContext:   stringsFromBytes(t.GetBeforeContext()),
+		AfterContext:    stringsFromBytes(t.GetAfterContext()),
+		StartLineNumber: int(t.GetStartLineNumber()),
+		Flags: models.GrepFlags{
+			FixedString:  f.GetFixedString(),
+			PrintNumbers: f.GetPrintNumbers(),
+			IgnoreCase:   f.GetIgnoreCase(),
+			Invert:       f.GetInvert(),
+			After:        int(f.GetAfter()),
+			Before:       int(f.GetBefore()),
+			CountOnly:    f.GetCountOnly(),
+			WordMatch:    f.GetWordMatch(),
+			LineMatch:    f.GetLineMatch(),
+			MaxCount:     int(f.GetMaxCount()),
+			MatchOffsets: f.GetMatchOffsets(),
+		},
+		Path:   t.GetPath(),
+		Offset: t.GetOffset(),
+		Length: t.GetLength(),
+		Size:   t.GetSize(),
+	}
+}
+
+// blockToPB converts a found block for sending over gRPC
+func blockToPB(b models.FoundBlock) *pb.FoundBlock {
+	block := &pb.FoundBlock{
+		StartLineNumber: int64(b.StartLineNumber),
+		Lines:           make([][]byte, len(b.Lines)),
+		Matches:         b.Matches,
+	}
+	for i, s := range b.Lines {
+		block.Lines[i] = []byte(s)
+	}
+	if b.Offsets != nil {
+		block.Offsets = make([]*pb.Offsets, len(b.Offsets))
+		for i, line := range b.Offsets {
+			bounds := make([]int32, 0, 2*len(line))
+			for _, o := range line {
+				bounds = append(bounds, int32(o[0]), int32(o[1]))
+			}
+			block.Offsets[i] = &pb.Offsets{Bounds: bounds}
+		}
+	}
+	return block
+}
+
+// stringsFromBytes converts lines received as bytes
+func stringsFromBytes(lines [][]byte) []string {
+	if lines == nil {
+		return nil
+	}
+	s := make([]string, len(lines))
+	for i, b := range lines {
+		s[i] = string(b)
+	}
+	return s
+}
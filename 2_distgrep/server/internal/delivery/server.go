@@ -1,29 +1,40 @@
 package delivery
 
 import (
+	"errors"
 	"fmt"
 	"grep-server/internal/models"
+	"grep-server/internal/pb"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
 )
 
+// mimeProtobuf is the Content-Type of tasks sent as a protobuf Task, answered with a protobuf Result
+const mimeProtobuf = "application/x-protobuf"
+
 // Server is the main server struct
 type Server struct {
 	e    *echo.Echo
 	srvc Service
+	opts Options
 }
 
 // Service is the interface for the service layer
 type Service interface {
 	Grep(req models.Request) (models.Response, error)
+	Search(req models.Request, emit func(models.FoundBlock) error) (models.Response, error)
+	Stat(path string) (models.FileInfo, error)
 }
 
 // NewServer creates a new server
-func NewServer(srvc Service) *Server {
+func NewServer(srvc Service, opts Options) *Server {
 	e := echo.New()
 
-	s := &Server{e: e, srvc: srvc}
+	s := &Server{e: e, srvc: srvc, opts: opts}
 
 	s.registerRoutes()
 	return s
@@ -31,31 +42,118 @@ func NewServer(srvc Service) *Server {
 
 // registerRoutes registers the routes for the server
 func (s *Server) registerRoutes() {
-	s.e.POST("/grep", s.grep)
+	// health checks stay open, as they reveal nothing
+	middleware := []echo.MiddlewareFunc{compression}
+	if s.opts.Token != "" {
+		middleware = []echo.MiddlewareFunc{requireToken(s.opts.Token), compression}
+	}
+	s.e.POST("/grep", s.grep, middleware...)
+	s.e.GET("/stat", s.stat, middleware...)
 	s.e.GET("/health", s.health)
 }
 
 // grep is the handler for the grep endpoint
 func (s *Server) grep(c echo.Context) error {
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), mimeProtobuf) {
+		return s.grepProtobuf(c)
+	}
 	var req models.Request
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+		return bodyError(c, err)
+	}
+
+	if err := decodeRequest(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
 	}
 
 	resp, err := s.srvc.Grep(req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"error": err.Error()})
+		return c.JSON(errorStatus(err), echo.Map{"error": err.Error()})
 	}
 
+	encodeResponse(&resp)
 	return c.JSON(http.StatusOK, resp)
 }
 
+// grepProtobuf is the handler for the grep endpoint when the task is sent as protobuf.
+// Lines are raw bytes, so neither the task nor the result needs an encoding;
+// errors are still reported as JSON.
+func (s *Server) grepProtobuf(c echo.Context) error {
+	data, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return bodyError(c, err)
+	}
+	var task pb.Task
+	if err := proto.Unmarshal(data, &task); err != nil {
+		return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+	}
+
+	resp, err := s.srvc.Grep(requestFromPB(&task))
+	if err != nil {
+		return c.JSON(errorStatus(err), echo.Map{"error": err.Error()})
+	}
+
+	result := &pb.Result{
+		Blocks: make([]*pb.FoundBlock, len(resp.FoundBlocks)),
+		Summary: &pb.Summary{
+			TaskId:    int64(resp.TaskID),
+			LineCount: int64(resp.LineCount),
+			Binary:    resp.Binary,
+		},
+	}
+	for i, b := range resp.FoundBlocks {
+		result.Blocks[i] = blockToPB(b)
+	}
+	if data, err = proto.Marshal(result); err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, mimeProtobuf, data)
+}
+
+// bodyError responds to a request whose body could not be read
+func bodyError(c echo.Context, err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": fmt.Sprintf("decompressed request body exceeds %d bytes", tooLarge.Limit)})
+	}
+	return c.JSON(http.StatusBadRequest, echo.Map{"error": "Invalid request body"})
+}
+
+// stat is the handler for the stat endpoint, which reports the size of a file under the root
+func (s *Server) stat(c echo.Context) error {
+	info, err := s.srvc.Stat(c.QueryParam("path"))
+	if err != nil {
+		return c.JSON(errorStatus(err), echo.Map{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
+// errorStatus returns the HTTP status for an error of the service layer
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrSizeMismatch):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrEmptyPattern), errors.Is(err, models.ErrInvalidRegex),
+		errors.Is(err, models.ErrNoRoot), errors.Is(err, models.ErrBadPath), errors.Is(err, models.ErrBadRange),
+		errors.Is(err, models.ErrTooLarge):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 // health is the handler for the health endpoint
 func (s *Server) health(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
-// Start starts the server
+// Start starts the server, over TLS if configured
 func (s *Server) Start(port int) error {
+	if s.opts.TLS != nil {
+		s.e.TLSServer.Addr = fmt.Sprintf(":%d", port)
+		s.e.TLSServer.TLSConfig = s.opts.TLS
+		return s.e.StartServer(s.e.TLSServer)
+	}
 	return s.e.Start(fmt.Sprintf(":%d", port))
 }
@@ -1,5 +1,24 @@
 package models
 
+import "errors"
+
+// Errors caused by the request itself rather than by the server
+var (
+	ErrEmptyPattern = errors.New("empty pattern")
+	ErrInvalidRegex = errors.New("invalid regex")
+	ErrBadEncoding  = errors.New("bad line encoding")
+	ErrNoRoot       = errors.New("file access is not enabled on this server")
+	ErrBadPath      = errors.New("path is not a local relative path")
+	ErrBadRange     = errors.New("invalid byte range")
+	ErrNotFound     = errors.New("no such file")
+	ErrSizeMismatch = errors.New("file size differs from the expected one")
+	ErrTooLarge     = errors.New("patterns too large")
+)
+
+// EncodingBase64 marks requests and responses whose lines are base64-encoded bytes.
+// It is used for data that is not valid UTF-8 and would be mangled in JSON strings.
+const EncodingBase64 = "base64"
+
 // GrepFlags is the struct for the grep flags
 type GrepFlags struct {
 	FixedString  bool `json:"fixed_string"`
@@ -9,27 +28,57 @@ type GrepFlags struct {
 	After        int  `json:"after"`
 	Before       int  `json:"before"`
 	CountOnly    bool `json:"count_only"`
+	WordMatch    bool `json:"word_match"`    // patterns only match whole words
+	LineMatch    bool `json:"line_match"`    // patterns only match whole lines
+	MaxCount     int  `json:"max_count"`     // select at most this many lines per request; 0 means no limit
+	MatchOffsets bool `json:"match_offsets"` // report where patterns match within the returned lines
 }
 
 // Request is the struct for the request
 type Request struct {
 	ID              int       `json:"id"`
 	Pattern         string    `json:"pattern"`
+	Patterns        []string  `json:"patterns"` // replaces Pattern unless null; a line matches if it matches any of them
 	Lines           []string  `json:"lines"`
 	BeforeContext   []string  `json:"before_context"`
 	AfterContext    []string  `json:"after_context"`
 	StartLineNumber int       `json:"start_line_number"`
 	Flags           GrepFlags `json:"flags"`
+	Encoding        string    `json:"encoding,omitempty"` // encoding of the lines, empty or EncodingBase64
+
+	// Path names a file under the server's root to read the lines from instead of Lines.
+	// The lines searched are those starting within [Offset, Offset+Length), with the
+	// context around them read from the file as well. Line numbers in the response
+	// are then relative to the first of these lines, which is line 0.
+	// With Size set, the file must be that many bytes long, so that a server holding
+	// another copy of it, or a copy still being written, does not return other lines.
+	Path   string `json:"path,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Length int64  `json:"length,omitempty"`
+	Size   int64  `json:"size,omitempty"`
 }
 
 // Response is the struct for the response
 type Response struct {
 	TaskID      int          `json:"task_id"`
 	FoundBlocks []FoundBlock `json:"found_blocks"`
+	Encoding    string       `json:"encoding,omitempty"`   // encoding of the block lines, empty or EncodingBase64
+	LineCount   int          `json:"line_count,omitempty"` // for requests with a path, the number of lines in the byte range
+	Binary      bool         `json:"binary,omitempty"`     // for requests with a path, whether the lines in the byte range hold NUL bytes
+}
+
+// FileInfo describes a file under the server's root
+type FileInfo struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
 }
 
 // FoundBlock is the struct for the found block
 type FoundBlock struct {
 	StartLineNumber int      `json:"start_line_number"`
 	Lines           []string `json:"lines"`
+	// Matches tells for each of Lines whether it is a selected line rather than context
+	Matches []bool `json:"matches"`
+	// Offsets holds for each of Lines the byte offsets [start, end) of its matches, if requested
+	Offsets [][][2]int `json:"offsets,omitempty"`
 }
@@ -0,0 +1,4 @@
+// Package pb holds the code generated from proto/grep.proto for the gRPC transport.
+package pb
+
+//go:generate protoc -I ../../../proto --go_out=. --go_opt=paths=source_relative,Mgrep.proto=grep-server/internal/pb --go-grpc_out=. --go-grpc_opt=paths=source_relative,Mgrep.proto=grep-server/internal/pb grep.proto
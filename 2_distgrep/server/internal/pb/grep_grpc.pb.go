@@ -0,0 +1,181 @@
+// Protocol between the distributed grep client and servers over gRPC.
+// It mirrors the JSON API of POST /grep and GET /stat; lines are raw bytes,
+// so no encoding is needed for data that is not valid UTF-8. POST /grep also
+// takes a Task and answers with a Result as application/x-protobuf bodies.
+//
+// The generated code lives in each module; regenerate it with go generate
+// in server/internal/pb and client/internal/pb.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: grep.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Grep_Search_FullMethodName = "/distgrep.v1.Grep/Search"
+	Grep_Stat_FullMethodName   = "/distgrep.v1.Grep/Stat"
+)
+
+// GrepClient is the client API for Grep service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Grep searches tasks of lines, or byte ranges of files under the server's root.
+type GrepClient interface {
+	// Search streams the blocks found in a task as they are built,
+	// followed by a single summary.
+	Search(ctx context.Context, in *Task, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchReply], error)
+	// Stat returns the size of a file under the server's root.
+	Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error)
+}
+
+type grepClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGrepClient(cc grpc.ClientConnInterface) GrepClient {
+	return &grepClient{cc}
+}
+
+func (c *grepClient) Search(ctx context.Context, in *Task, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchReply], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Grep_ServiceDesc.Streams[0], Grep_Search_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Task, SearchReply]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Grep_SearchClient = grpc.ServerStreamingClient[SearchReply]
+
+func (c *grepClient) Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, Grep_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GrepServer is the server API for Grep service.
+// All implementations must embed UnimplementedGrepServer
+// for forward compatibility.
+//
+// Grep searches tasks of lines, or byte ranges of files under the server's root.
+type GrepServer interface {
+	// Search streams the blocks found in a task as they are built,
+	// followed by a single summary.
+	Search(*Task, grpc.ServerStreamingServer[SearchReply]) error
+	// Stat returns the size of a file under the server's root.
+	Stat(context.Context, *StatRequest) (*FileInfo, error)
+	mustEmbedUnimplementedGrepServer()
+}
+
+// UnimplementedGrepServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGrepServer struct{}
+
+func (UnimplementedGrepServer) Search(*Task, grpc.ServerStreamingServer[SearchReply]) error {
+	return status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedGrepServer) Stat(context.Context, *StatRequest) (*FileInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedGrepServer) mustEmbedUnimplementedGrepServer() {}
+func (UnimplementedGrepServer) testEmbeddedByValue()              {}
+
+// UnsafeGrepServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GrepServer will
+// result in compilation errors.
+type UnsafeGrepServer interface {
+	mustEmbedUnimplementedGrepServer()
+}
+
+func RegisterGrepServer(s grpc.ServiceRegistrar, srv GrepServer) {
+	// If the following call pancis, it indicates UnimplementedGrepServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Grep_ServiceDesc, srv)
+}
+
+func _Grep_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Task)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GrepServer).Search(m, &grpc.GenericServerStream[Task, SearchReply]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Grep_SearchServer = grpc.ServerStreamingServer[SearchReply]
+
+func _Grep_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GrepServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Grep_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GrepServer).Stat(ctx, req.(*StatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Grep_ServiceDesc is the grpc.ServiceDesc for Grep service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Grep_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "distgrep.v1.Grep",
+	HandlerType: (*GrepServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Stat",
+			Handler:    _Grep_Stat_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _Grep_Search_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grep.proto",
+}
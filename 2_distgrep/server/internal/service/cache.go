@@ -0,0 +1,108 @@
+package service
+
+import (
+	"container/list"
+	"grep-server/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCachedBytes bounds the total length of the pattern sets whose compiled matchers
+// a service keeps; their memory grows with it. A client sends the same patterns with
+// every chunk of a run, so a few entries cover the runs in progress.
+const maxCachedBytes = 4 << 20
+
+// compiled is the matcher and finder built for a pattern set
+type compiled struct {
+	m      matcher
+	finder *offsetFinder // nil until a request asks for match offsets
+}
+
+// matcherCache keeps the most recently used compiled pattern sets, so that the
+// chunks of a run do not rebuild the same matcher over and over.
+// Matchers and finders are only read once built, so requests may share them.
+type matcherCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // values of the list are *cacheEntry
+	lru     *list.List               // most recently used first
+	size    int                      // total length of the keys of the entries
+}
+
+type cacheEntry struct {
+	key string
+	compiled
+}
+
+func newMatcherCache() *matcherCache {
+	return &matcherCache{entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// get returns the matcher for patterns and flags, and the finder if withFinder is set,
+// building and caching whichever is missing
+func (c *matcherCache) get(patterns []string, flags models.GrepFlags, withFinder bool) (compiled, error) {
+	key := cacheKey(patterns, flags)
+	c.mu.Lock()
+	var cached compiled
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		cached = e.Value.(*cacheEntry).compiled
+	}
+	c.mu.Unlock()
+	if cached.m != nil && (cached.finder != nil || !withFinder) {
+		return cached, nil
+	}
+
+	// compiling may take a while, so it is done outside the lock; requests racing
+	// on the same new patterns compile them more than once, which is harmless
+	var err error
+	if cached.m == nil {
+		if cached.m, err = newMatcher(patterns, flags); err != nil {
+			return cached, err
+		}
+	}
+	if withFinder && cached.finder == nil {
+		if cached.finder, err = newFinder(patterns, flags); err != nil {
+			return cached, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		if entry.finder == nil {
+			entry.finder = cached.finder
+		}
+		c.lru.MoveToFront(e)
+		return cached, nil
+	}
+	if len(key) > maxCachedBytes {
+		return cached, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, compiled: cached})
+	c.size += len(key)
+	for c.size > maxCachedBytes {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.size -= len(oldest.Value.(*cacheEntry).key)
+	}
+	return cached, nil
+}
+
+// cacheKey identifies patterns together with the flags that change how they are compiled
+func cacheKey(patterns []string, flags models.GrepFlags) string {
+	var b strings.Builder
+	for _, f := range []bool{flags.FixedString, flags.IgnoreCase, flags.WordMatch, flags.LineMatch} {
+		b.WriteString(strconv.FormatBool(f))
+		b.WriteByte(',')
+	}
+	// lengths keep patterns holding the separator from running into each other
+	for _, p := range patterns {
+		b.WriteString(strconv.Itoa(len(p)))
+		b.WriteByte(':')
+		b.WriteString(p)
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"grep-server/internal/models"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backWindow is how much of a file is read at first when looking back for leading context;
+// it is doubled until enough lines are found
+const backWindow = 64 << 10
+
+// Stat returns the size of the file at path under the server's root.
+func (s *Service) Stat(path string) (models.FileInfo, error) {
+	f, err := s.open(path)
+	if err != nil {
+		return models.FileInfo{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return models.FileInfo{}, err
+	}
+	return models.FileInfo{Path: path, Size: info.Size()}, nil
+}
+
+// open opens a regular file under the server's root, which it cannot escape
+func (s *Service) open(path string) (*os.File, error) {
+	if s.root == nil {
+		return nil, models.ErrNoRoot
+	}
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("%w: %q", models.ErrBadPath, path)
+	}
+	f, err := s.root.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", models.ErrNotFound, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s is not a regular file", models.ErrBadPath, path)
+	}
+	return f, nil
+}
+
+// readRange fills the lines and context of a request naming a byte range of a file.
+// A line belongs to the range it starts in, so ranges splitting a file at arbitrary
+// offsets together cover every line exactly once.
+func (s *Service) readRange(req *models.Request) error {
+	if req.Offset < 0 || req.Length <= 0 {
+		return fmt.Errorf("%w: offset %d, length %d", models.ErrBadRange, req.Offset, req.Length)
+	}
+	f, err := s.open(req.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if req.Size > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != req.Size {
+			return fmt.Errorf("%w: %s has %d bytes, expected %d", models.ErrSizeMismatch, req.Path, info.Size(), req.Size)
+		}
+	}
+
+	// the lines of the range start after the first newline before Offset
+	start := req.Offset
+	from := max(0, start-1)
+	r := bufio.NewReader(io.NewSectionReader(f, from, math.MaxInt64-from))
+	if start > 0 {
+		skipped, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		start += int64(len(skipped)) - 1
+	}
+
+	req.Lines = nil
+	end := req.Offset + req.Length
+	for pos := start; pos < end; {
+		line, ok, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		req.Lines = append(req.Lines, strings.TrimSuffix(line, "\n"))
+		pos += int64(len(line))
+	}
+	req.AfterContext = nil
+	for range req.Flags.After {
+		line, ok, err := readLine(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		req.AfterContext = append(req.AfterContext, strings.TrimSuffix(line, "\n"))
+	}
+
+	req.BeforeContext, err = linesBefore(f, start, req.Flags.Before)
+	return err
+}
+
+// readLine reads the next line with its newline; a final line without newline is still a line
+func readLine(r *bufio.Reader) (string, bool, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		return line, line != "", nil
+	}
+	return line, err == nil, err
+}
+
+// linesBefore returns up to n lines preceding the line starting at offset start
+func linesBefore(f io.ReaderAt, start int64, n int) ([]string, error) {
+	if n <= 0 || start == 0 {
+		return nil, nil
+	}
+	for window := int64(backWindow); ; window *= 2 {
+		from := max(0, start-window)
+		data := make([]byte, start-from)
+		if _, err := f.ReadAt(data, from); err != nil {
+			return nil, err
+		}
+		// data ends with the newline of the last line before start
+		lines := strings.Split(string(data[:len(data)-1]), "\n")
+		if from > 0 {
+			// the first line may have started before the window
+			lines = lines[1:]
+		}
+		if len(lines) >= n || from == 0 {
+			return lines[max(0, len(lines)-n):], nil
+		}
+	}
+}
+
+// hasNUL reports whether any of lines holds a NUL byte, which makes grep take data as binary
+func hasNUL(lines []string) bool {
+	for _, s := range lines {
+		if strings.IndexByte(s, 0) >= 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"grep-server/internal/models"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// nonWord matches a character that is not part of a word for -w: words are
+// made of letters, digits and underscores
+const nonWord = `[^\pL\pN_]`
+
+// matcher reports whether a line matches any of the patterns of a request
+type matcher interface {
+	MatchString(s string) bool
+}
+
+// newMatcher builds a single matcher for all patterns, so every line is scanned once
+// no matter how many patterns there are
+func newMatcher(patterns []string, flags models.GrepFlags) (matcher, error) {
+	// an empty pattern list, e.g. from an empty pattern file, selects no line
+	if len(patterns) == 0 {
+		return newAhoCorasick(nil), nil
+	}
+	if flags.FixedString {
+		if flags.IgnoreCase {
+			lower := make([]string, len(patterns))
+			for i, p := range patterns {
+				lower[i] = strings.ToLower(p)
+			}
+			patterns = lower
+		}
+		var m matcher
+		switch {
+		case flags.LineMatch:
+			m = newLineSet(patterns)
+		case flags.WordMatch:
+			// word boundaries are easier to check with a regexp
+			return compileAlternation(quoteMeta(patterns), flags.IgnoreCase, wordMatch)
+		default:
+			m = newLiteralMatcher(patterns)
+		}
+		if flags.IgnoreCase {
+			m = foldMatcher{m}
+		}
+		return m, nil
+	}
+
+	switch {
+	case flags.LineMatch:
+		return compileAlternation(patterns, flags.IgnoreCase, lineMatch)
+	case flags.WordMatch:
+		return compileAlternation(patterns, flags.IgnoreCase, wordMatch)
+	}
+	return compileAlternation(patterns, flags.IgnoreCase, nil)
+}
+
+// lineMatch wraps a regexp so that it only matches whole lines
+func lineMatch(re string) string {
+	return "^(?:" + re + ")$"
+}
+
+// wordMatch wraps a regexp so that it only matches where the match is neither
+// preceded nor followed by a word character
+func wordMatch(re string) string {
+	return "(?:^|" + nonWord + ")(?:" + re + ")(?:" + nonWord + "|$)"
+}
+
+// offsetFinder locates the matches of all patterns within a line.
+// Like grep, it prefers the leftmost longest match.
+type offsetFinder struct {
+	re *regexp.Regexp
+	// with -w, re matches a word between two non-word characters and captures the word;
+	// lines are padded with spaces so that words at either end of the line are found too
+	word bool
+}
+
+// newFinder builds the finder for patterns
+func newFinder(patterns []string, flags models.GrepFlags) (*offsetFinder, error) {
+	if flags.FixedString {
+		patterns = quoteMeta(patterns)
+	}
+	f := &offsetFinder{word: flags.WordMatch && !flags.LineMatch}
+	var wrap func(string) string
+	switch {
+	case flags.LineMatch:
+		wrap = lineMatch
+	case f.word:
+		wrap = func(re string) string { return nonWord + "(" + re + ")" + nonWord }
+	}
+	re, err := compileAlternation(patterns, flags.IgnoreCase, wrap)
+	if err != nil {
+		return nil, err
+	}
+	re.Longest()
+	f.re = re
+	return f, nil
+}
+
+// offsets returns the byte offsets of the non-empty matches in s
+func (f *offsetFinder) offsets(s string) [][2]int {
+	var offsets [][2]int
+	// like grep, empty matches are neither printed nor highlighted
+	add := func(start, end int) {
+		if end > start {
+			offsets = append(offsets, [2]int{start, end})
+		}
+	}
+
+	if !f.word {
+		for _, m := range f.re.FindAllStringIndex(s, -1) {
+			add(m[0], m[1])
+		}
+		return offsets
+	}
+
+	padded := " " + s + " "
+	for pos := 0; pos < len(padded); {
+		m := f.re.FindStringSubmatchIndex(padded[pos:])
+		if m == nil {
+			break
+		}
+		add(pos+m[2]-1, pos+m[3]-1)
+		// the non-word character ending this word may start the next one;
+		// the leading non-word character makes m[3] at least 1
+		pos += m[3]
+	}
+	return offsets
+}
+
+// compileAlternation compiles a regexp matching any of patterns, wrapped by wrap if not nil
+func compileAlternation(patterns []string, ignoreCase bool, wrap func(string) string) (*regexp.Regexp, error) {
+	// (?:) keeps each pattern's own alternations from mixing with the others
+	alts := make([]string, len(patterns))
+	for i, p := range patterns {
+		alts[i] = "(?:" + p + ")"
+	}
+	pat := strings.Join(alts, "|")
+	if wrap != nil {
+		pat = wrap(pat)
+	}
+	if ignoreCase {
+		pat = "(?i)" + pat
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		// report the offending pattern rather than the combined one
+		for _, p := range patterns {
+			if _, perr := regexp.Compile(p); perr != nil {
+				err = perr
+				break
+			}
+		}
+		return nil, fmt.Errorf("%w: %w", models.ErrInvalidRegex, err)
+	}
+	return re, nil
+}
+
+// quoteMeta escapes fixed strings for use in a regexp
+func quoteMeta(patterns []string) []string {
+	quoted := make([]string, len(patterns))
+	for i, p := range patterns {
+		quoted[i] = regexp.QuoteMeta(p)
+	}
+	return quoted
+}
+
+// lineSet matches lines equal to one of a set of strings
+type lineSet map[string]struct{}
+
+// newLineSet builds the set of patterns
+func newLineSet(patterns []string) lineSet {
+	set := make(lineSet, len(patterns))
+	for _, p := range patterns {
+		set[p] = struct{}{}
+	}
+	return set
+}
+
+func (set lineSet) MatchString(s string) bool {
+	_, ok := set[s]
+	return ok
+}
+
+// newLiteralMatcher matches fixed strings: a plain substring search for a single
+// pattern and an Aho–Corasick automaton for several
+func newLiteralMatcher(patterns []string) matcher {
+	if len(patterns) == 1 {
+		return substringMatcher(patterns[0])
+	}
+	return newAhoCorasick(patterns)
+}
+
+// substringMatcher matches lines containing the string
+type substringMatcher string
+
+func (m substringMatcher) MatchString(s string) bool {
+	return strings.Contains(s, string(m))
+}
+
+// foldMatcher matches lowercased lines against lowercased patterns
+type foldMatcher struct {
+	m matcher
+}
+
+func (m foldMatcher) MatchString(s string) bool {
+	return m.m.MatchString(strings.ToLower(s))
+}
+
+// ahoCorasick finds any of a set of strings in a single pass over a line.
+// Only the edges of the trie are stored, sorted by byte in one array per automaton,
+// so memory grows with the total length of the patterns rather than 256 times it.
+// Missing edges are resolved through failure links, which keeps matching linear in
+// the length of the line; the root keeps a full table, as every byte passes through it.
+type ahoCorasick struct {
+	root  [256]int32 // root[b] is the state after reading b at the root
+	first []int32    // the edges of state s are labels[first[s]:first[s+1]]
+	label []byte     // edge bytes, sorted within each state
+	to    []int32    // edge targets, parallel to label
+	fail  []int32    // fail[s] is the state of the longest proper suffix of s in the trie
+	out   []bool     // out[s] is set if a pattern ends in s or in one of its suffixes
+}
+
+// newAhoCorasick builds the automaton for patterns
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	// trie of the patterns, with the edges of each node sorted by byte; states are
+	// numbered in breadth-first order, so the edges of all states form one sorted array
+	sorted := slices.Clone(patterns)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	ac := &ahoCorasick{first: []int32{0}, out: []bool{false}}
+	// each state covers the patterns sorted[lo:hi] sharing its prefix of length depth
+	type node struct{ lo, hi, depth int }
+	queue := []node{{0, len(sorted), 0}}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		state := int32(len(ac.out) - len(queue) - 1)
+		for i := n.lo; i < n.hi; {
+			if len(sorted[i]) == n.depth {
+				ac.out[state] = true
+				i++
+				continue
+			}
+			b := sorted[i][n.depth]
+			j := i + 1
+			for j < n.hi && len(sorted[j]) > n.depth && sorted[j][n.depth] == b {
+				j++
+			}
+			ac.label = append(ac.label, b)
+			ac.to = append(ac.to, int32(len(ac.out)))
+			ac.out = append(ac.out, false)
+			queue = append(queue, node{i, j, n.depth + 1})
+			i = j
+		}
+		ac.first = append(ac.first, int32(len(ac.label)))
+	}
+
+	// failure links in breadth-first order, which is the order of the states
+	ac.fail = make([]int32, len(ac.out))
+	for e := ac.first[0]; e < ac.first[1]; e++ {
+		ac.root[ac.label[e]] = ac.to[e]
+	}
+	for state := int32(1); state < int32(len(ac.out)); state++ {
+		ac.out[state] = ac.out[state] || ac.out[ac.fail[state]]
+		for e := ac.first[state]; e < ac.first[state+1]; e++ {
+			ac.fail[ac.to[e]] = ac.step(ac.fail[state], ac.label[e])
+		}
+	}
+	return ac
+}
+
+// step returns the state after reading b in state
+func (ac *ahoCorasick) step(state int32, b byte) int32 {
+	for state != 0 {
+		edges := ac.label[ac.first[state]:ac.first[state+1]]
+		if i, ok := slices.BinarySearch(edges, b); ok {
+			return ac.to[int(ac.first[state])+i]
+		}
+		state = ac.fail[state]
+	}
+	return ac.root[b]
+}
+
+func (ac *ahoCorasick) MatchString(s string) bool {
+	// the root is an output state only for an empty pattern, which matches every line
+	if ac.out[0] {
+		return true
+	}
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = ac.step(state, s[i])
+		if ac.out[state] {
+			return true
+		}
+	}
+	return false
+}
@@ -1,78 +1,111 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"grep-server/internal/models"
-	"regexp"
+	"os"
 	"strconv"
-	"strings"
 )
 
+// MaxPatternBytes is the maximum total length of the patterns of a request
+const MaxPatternBytes = 1 << 20
+
 // Service is the struct for the service layer
 type Service struct {
+	root     *os.Root // files requests may read; nil when file access is not enabled
+	matchers *matcherCache
 }
 
-// NewService creates a new service
-func NewService() *Service {
-	return &Service{}
+// NewService creates a new service. Requests may name files under root to search;
+// an empty root leaves file access disabled.
+func NewService(root string) (*Service, error) {
+	s := &Service{matchers: newMatcherCache()}
+	if root != "" {
+		r, err := os.OpenRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		s.root = r
+	}
+	return s, nil
 }
 
 // Grep is the function for the grep endpoint
 func (s *Service) Grep(req models.Request) (models.Response, error) {
+	var blocks []models.FoundBlock
+	resp, err := s.Search(req, func(b models.FoundBlock) error {
+		blocks = append(blocks, b)
+		return nil
+	})
+	resp.FoundBlocks = blocks
+	return resp, err
+}
+
+// Search is Grep passing the found blocks to emit as they are built rather than
+// collecting them in the response, for transports that stream them.
+// It stops at the first error returned by emit.
+func (s *Service) Search(req models.Request, emit func(models.FoundBlock) error) (models.Response, error) {
+	if req.Path != "" {
+		return s.searchRange(req, emit)
+	}
 	resp := models.Response{TaskID: req.ID}
 
 	lines := req.Lines
-	pattern := req.Pattern
 	flags := req.Flags
 
-	if pattern == "" {
-		return resp, errors.New("empty pattern")
+	// requests without a pattern list carry a single pattern
+	patterns := req.Patterns
+	if patterns == nil {
+		if req.Pattern == "" {
+			return resp, models.ErrEmptyPattern
+		}
+		patterns = []string{req.Pattern}
 	}
 
-	var re *regexp.Regexp
-	var err error
-	if flags.FixedString {
-	} else {
-		pat := pattern
-		if flags.IgnoreCase {
-			pat = "(?i)" + pattern
-		}
-		re, err = regexp.Compile(pat)
-		if err != nil {
-			return resp, fmt.Errorf("invalid regex: %w", err)
-		}
+	// the memory taken by a matcher grows with the length of the patterns
+	size := 0
+	for _, p := range patterns {
+		size += len(p)
+	}
+	if size > MaxPatternBytes {
+		return resp, fmt.Errorf("%w: %d bytes, at most %d are accepted", models.ErrTooLarge, size, MaxPatternBytes)
 	}
 
-	matchesLine := func(s string) bool {
-		if flags.FixedString {
-			if flags.IgnoreCase {
-				return strings.Contains(strings.ToLower(s), strings.ToLower(pattern))
-			}
-			return strings.Contains(s, pattern)
-		}
-		return re.MatchString(s)
+	// match offsets are only needed by clients printing matched parts or highlighting them
+	c, err := s.matchers.get(patterns, flags, flags.MatchOffsets && !flags.CountOnly)
+	if err != nil {
+		return resp, err
+	}
+	m := c.m
+	var finder *offsetFinder
+	if flags.MatchOffsets && !flags.CountOnly {
+		finder = c.finder
+	}
+
+	selected := func(s string) bool {
+		return m.MatchString(s) != flags.Invert
 	}
 
 	matched := make([]bool, len(lines))
 	matchCount := 0
+	// like grep, stop at the limit; later lines can at most be trailing context
+	limited := false
 	for i, s := range lines {
-		isMatch := matchesLine(s)
-		if flags.Invert {
-			isMatch = !isMatch
-		}
-		matched[i] = isMatch
-		if isMatch {
+		matched[i] = selected(s)
+		if matched[i] {
 			matchCount++
+			if matchCount == flags.MaxCount {
+				limited = true
+				break
+			}
 		}
 	}
 
 	if flags.CountOnly {
-		resp.FoundBlocks = []models.FoundBlock{{
+		return resp, emit(models.FoundBlock{
 			StartLineNumber: 0,
 			Lines:           []string{strconv.Itoa(matchCount)},
-		}}
-		return resp, nil
+		})
 	}
 
 	before := flags.Before
@@ -133,10 +166,15 @@ func (s *Service) Grep(req models.Request) (models.Response, error) {
 		}
 
 		blockLines := make([]string, 0)
+		// context lines belong to neighbouring chunks, where they may be selected themselves
+		blockMatches := make([]bool, 0)
 
 		if useBefore > 0 {
 			startIdx := len(req.BeforeContext) - useBefore
 			blockLines = append(blockLines, req.BeforeContext[startIdx:]...)
+			for _, s := range req.BeforeContext[startIdx:] {
+				blockMatches = append(blockMatches, selected(s))
+			}
 		}
 
 		clampedStart := start
@@ -149,10 +187,22 @@ func (s *Service) Grep(req models.Request) (models.Response, error) {
 		}
 		if clampedStart <= clampedEnd {
 			blockLines = append(blockLines, lines[clampedStart:clampedEnd+1]...)
+			blockMatches = append(blockMatches, matched[clampedStart:clampedEnd+1]...)
 		}
 
 		if useAfter > 0 {
 			blockLines = append(blockLines, req.AfterContext[:useAfter]...)
+			for _, s := range req.AfterContext[:useAfter] {
+				blockMatches = append(blockMatches, !limited && selected(s))
+			}
+		}
+
+		var blockOffsets [][][2]int
+		if finder != nil {
+			blockOffsets = make([][][2]int, len(blockLines))
+			for i, s := range blockLines {
+				blockOffsets[i] = finder.offsets(s)
+			}
 		}
 
 		blockStartAbs := req.StartLineNumber + clampedStart - useBefore
@@ -169,11 +219,41 @@ func (s *Service) Grep(req models.Request) (models.Response, error) {
 			blockLines = withNums
 		}
 
-		resp.FoundBlocks = append(resp.FoundBlocks, models.FoundBlock{
+		err := emit(models.FoundBlock{
 			StartLineNumber: blockStartAbs,
 			Lines:           blockLines,
+			Matches:         blockMatches,
+			Offsets:         blockOffsets,
 		})
+		if err != nil {
+			return resp, err
+		}
 	}
 
 	return resp, nil
 }
+
+// searchRange searches the lines of a byte range of a file under the server's root
+func (s *Service) searchRange(req models.Request, emit func(models.FoundBlock) error) (models.Response, error) {
+	// the server does not know where the range starts in the file, so it cannot number lines
+	if req.Flags.PrintNumbers {
+		return models.Response{TaskID: req.ID}, fmt.Errorf("%w: line numbers are not known for byte ranges", models.ErrBadRange)
+	}
+	if err := s.readRange(&req); err != nil {
+		return models.Response{TaskID: req.ID}, err
+	}
+	req.Path = ""
+	// number lines from 1 at the first context line, then from 0 at the first line of the range
+	first := len(req.BeforeContext) + 1
+	req.StartLineNumber = first
+	resp, err := s.Search(req, func(b models.FoundBlock) error {
+		b.StartLineNumber -= first
+		return emit(b)
+	})
+	if err != nil {
+		return resp, err
+	}
+	resp.LineCount = len(req.Lines)
+	resp.Binary = hasNUL(req.Lines)
+	return resp, nil
+}